@@ -7,12 +7,16 @@ import (
 	"os/exec"
 	"path/filepath"
 	stdruntime "runtime"
+	"strings"
 	"sync"
 	"time"
 
 	"switch-input/services"
 )
 
+// backendHealthCheckInterval 输入法后端健康检查间隔
+const backendHealthCheckInterval = 30 * time.Second
+
 // App struct
 type App struct {
 	ctx            context.Context
@@ -20,27 +24,28 @@ type App struct {
 	inputService   *services.InputService
 	matcherService *services.MatcherService
 	loggerService  *services.LoggerService
+	metricsService *services.MetricsService
 	isRunning      bool
 	isRunningMutex sync.RWMutex
+	onStateChange  func()
+	currentInput   string
+	lastWindow     *services.WindowInfo // 上一个活动窗口，仅在窗口监控协程中访问
+	lastProfile    string               // 上一次匹配时启用的配置方案，仅在窗口监控协程中访问
+	statusMutex    sync.RWMutex
+	flagOverrides  map[string]string // 命令行参数指定的配置项，优先级最高
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
-	// 获取用户家目录
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		homeDir = "." // fallback to current directory
-	}
-
 	// 创建配置目录和日志目录的绝对路径
-	configDir := filepath.Join(homeDir, ".switch-input")
+	configDir := defaultConfigDir()
 	logDir := filepath.Join(configDir, "logs")
 
 	// 确保目录存在
 	os.MkdirAll(configDir, 0755)
 	os.MkdirAll(logDir, 0755)
 
-	configPath := filepath.Join(configDir, "config.json")
+	configPath := services.FindConfigFile(configDir)
 	logPath := filepath.Join(logDir, "app.log")
 
 	return &App{
@@ -48,7 +53,35 @@ func NewApp() *App {
 		inputService:   services.NewInputService(),
 		matcherService: services.NewMatcherService(configPath),
 		loggerService:  services.NewLoggerService(logPath),
+		metricsService: services.NewMetricsService(filepath.Join(configDir, "metrics")),
+	}
+}
+
+// defaultConfigDir 返回用户配置目录：设置了 $XDG_CONFIG_HOME 时为 $XDG_CONFIG_HOME/switch-input，否则为 ~/.switch-input
+// 已经在使用 ~/.switch-input 的用户（该目录存在而 $XDG_CONFIG_HOME/switch-input 不存在）继续使用原目录，
+// 其中的配置、日志、备份和 rules.d 不会被忽略
+func defaultConfigDir() string {
+	// 获取用户家目录
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		homeDir = "." // fallback to current directory
+	}
+	legacyDir := filepath.Join(homeDir, ".switch-input")
+
+	if xdgConfigHome := os.Getenv("XDG_CONFIG_HOME"); xdgConfigHome != "" {
+		xdgDir := filepath.Join(xdgConfigHome, "switch-input")
+		if !dirExists(xdgDir) && dirExists(legacyDir) {
+			return legacyDir
+		}
+		return xdgDir
 	}
+	return legacyDir
+}
+
+// dirExists 判断目录是否存在
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
 }
 
 // startup is called when the app starts. The context is saved
@@ -63,19 +96,56 @@ func (a *App) startup(ctx context.Context) {
 
 	a.loggerService.LogInfo("应用程序启动")
 
+	// 启动指标服务
+	if err := a.metricsService.Start(); err != nil {
+		a.loggerService.LogError(fmt.Sprintf("启动指标服务失败: %v", err))
+		fmt.Printf("Failed to start metrics: %v\n", err)
+	}
+
+	// 环境变量和命令行参数覆盖配置文件中的值
+	envOverrides, err := services.EnvOverrides(os.Environ())
+	if err != nil {
+		a.loggerService.LogError(fmt.Sprintf("忽略无法识别的环境变量: %v", err))
+	}
+	if err := a.matcherService.SetOverrides(envOverrides, a.flagOverrides); err != nil {
+		a.loggerService.LogError(fmt.Sprintf("忽略无效的配置覆盖: %v", err))
+		fmt.Printf("%v\n", err)
+	}
+
+	// 加载配置过程中的提示（例如写回配置文件失败）记录到日志
+	a.matcherService.SetNoticeCallback(a.onConfigNotice)
+
 	// 加载配置文件
 	if err := a.matcherService.LoadConfig(); err != nil {
 		errorMsg := fmt.Sprintf("加载配置文件失败: %v", err)
 		a.loggerService.LogError(errorMsg)
 		fmt.Printf("Failed to load config: %v\n", err)
-		// 不显示对话框，只记录日志
-		return
+
+		// 记录配置文件中的所有问题，便于一次改完
+		if result, err := a.ValidateConfig(); err == nil {
+			for _, issue := range result.Issues {
+				a.loggerService.LogError(fmt.Sprintf("配置问题: %s", issue))
+			}
+		}
+		// 不显示对话框，只记录日志；其余服务照常启动，
+		// 改正配置文件后由下面的监听自动加载，在此之前不会切换输入法
 	}
 
 	// 根据配置设置日志服务
 	config := a.matcherService.GetConfig()
 	if config != nil {
 		a.loggerService.SetLogging(config.General.EnableLogging)
+		for _, source := range a.matcherService.GetRuleSources() {
+			a.loggerService.LogInfo(fmt.Sprintf("加载规则文件: rules.d/%s（%d 条规则）", source.Name, len(source.Rules)))
+		}
+		if len(config.Locked) > 0 {
+			a.loggerService.LogInfo(fmt.Sprintf("管理策略锁定的配置项: %s", strings.Join(config.Locked, ", ")))
+		}
+	}
+
+	a.lastProfile, _ = a.matcherService.ActiveProfile()
+	if a.lastProfile != "" {
+		a.loggerService.LogInfo(fmt.Sprintf("启用配置方案: %s", a.lastProfile))
 	}
 
 	// 设置窗口变化回调
@@ -84,11 +154,20 @@ func (a *App) startup(ctx context.Context) {
 	// 设置规则匹配回调
 	a.matcherService.SetRuleMatchCallback(a.onRuleMatch)
 
+	// 后端切换时记录日志，并启动定期健康检查
+	a.inputService.SetBackendChangeCallback(a.onBackendChange)
+	go a.inputService.StartHealthChecks(backendHealthCheckInterval)
+
+	// 配置文件变化后自动重新加载
+	a.matcherService.SetReloadCallback(a.onConfigReload)
+	go a.matcherService.StartWatching()
+
 	// 启动窗口监控
 	go a.windowService.StartMonitoring()
 
 	a.loggerService.LogInfo("输入法自动切换服务已启动")
 	fmt.Println("输入法自动切换服务已启动")
+	a.notifyStateChange()
 }
 
 // onWindowChange 窗口变化处理
@@ -97,38 +176,221 @@ func (a *App) onWindowChange(window *services.WindowInfo) {
 		return
 	}
 
+	// 全局忽略的应用（启动器、截图工具等）不触发任何切换
+	if a.matcherService.IsIgnored(window) {
+		fmt.Printf("忽略窗口: %s (%s)\n", window.AppName, window.WindowName)
+		return
+	}
+
 	a.loggerService.LogWindowChange(window.AppName, window.WindowName)
 	fmt.Printf("窗口切换: %s (%s)\n", window.AppName, window.WindowName)
 
-	// 查找匹配的规则
-	rule := a.matcherService.MatchWindow(window)
+	a.metricsService.RecordStage(services.StageWindowDetect, window.DetectDuration)
+
+	config := a.matcherService.GetConfig()
+	if config == nil {
+		return
+	}
+
+	// 兜底行为为恢复时，记录离开的应用正在使用的输入法
+	if config.General.Fallback.Mode == services.FallbackRestore && a.lastWindow != nil {
+		if current, err := a.inputService.GetCurrentInput(); err == nil {
+			a.inputService.RememberInput(a.lastWindow.AppName, current.ID)
+		}
+	}
+	a.lastWindow = window
+
+	// 配置方案按时间或主机名自动切换时记录日志并刷新状态栏
+	if profile, _ := a.matcherService.ActiveProfile(); profile != a.lastProfile {
+		a.loggerService.LogInfo(fmt.Sprintf("配置方案切换: %s -> %s", profileDisplayName(a.lastProfile), profileDisplayName(profile)))
+		a.lastProfile = profile
+		a.notifyStateChange()
+	}
+
+	// 查找匹配的规则，开启匹配过程日志时同时生成解释
+	matchStart := time.Now()
+	var rule *services.Rule
+	var trace *services.MatchExplanation
+	if config.General.LogMatchTrace {
+		trace = a.matcherService.Explain(window)
+		rule = trace.Decision
+	} else {
+		rule = a.matcherService.MatchWindow(window)
+	}
+	a.metricsService.RecordStage(services.StageRuleMatch, time.Since(matchStart))
 	if rule != nil {
-		a.onRuleMatch(rule, window)
+		a.handleRuleMatch(rule, window, trace)
+	} else {
+		a.applyFallback(window, config.General)
 	}
 }
 
+// applyFallback 没有规则匹配时按配置执行兜底行为
+func (a *App) applyFallback(window *services.WindowInfo, general services.GeneralConfig) {
+	var inputs services.InputList
+	switch general.Fallback.Mode {
+	case services.FallbackDefault:
+		inputs = general.Fallback.Input
+		a.loggerService.LogFallback(window.AppName, general.Fallback.Mode, inputs.String(), "切换到默认输入法")
+	case services.FallbackRestore:
+		inputID, exists := a.inputService.RememberedInput(window.AppName)
+		if !exists {
+			a.loggerService.LogFallback(window.AppName, general.Fallback.Mode, "", "没有该应用的输入法记录，保持不变")
+			return
+		}
+		inputs = services.InputList{inputID}
+		a.loggerService.LogFallback(window.AppName, general.Fallback.Mode, inputID, "恢复上次使用的输入法")
+	default:
+		a.loggerService.LogFallback(window.AppName, services.FallbackNone, "", "保持当前输入法")
+		return
+	}
+
+	time.Sleep(time.Duration(general.SwitchDelay) * time.Millisecond)
+	a.switchToInputs(window.AppName, inputs)
+}
+
+// onBackendChange 输入法后端切换处理
+func (a *App) onBackendChange(from, to string) {
+	message := fmt.Sprintf("输入法后端切换: %s -> %s", from, to)
+	if to == "none" {
+		a.loggerService.LogError(message + "（所有后端均不可用）")
+	} else {
+		a.loggerService.LogWarn(message)
+	}
+	fmt.Println(message)
+}
+
 // onRuleMatch 规则匹配处理
 func (a *App) onRuleMatch(rule *services.Rule, window *services.WindowInfo) {
+	a.handleRuleMatch(rule, window, nil)
+}
+
+// handleRuleMatch 规则匹配处理，trace 不为空时写入规则匹配日志
+func (a *App) handleRuleMatch(rule *services.Rule, window *services.WindowInfo, trace *services.MatchExplanation) {
 	config := a.matcherService.GetConfig()
 	if config == nil {
 		return
 	}
 
-	a.loggerService.LogRuleMatch(rule.AppName, rule.Input)
+	// 被熔断暂停的规则在重试时间到达前直接跳过
+	if !a.matcherService.AllowRule(rule) {
+		fmt.Printf("规则已暂停，跳过: %s -> %s\n", rule.AppName, rule.Input)
+		a.metricsService.RecordRuleOutcome(rule.ID, ruleMetricsName(rule), services.OutcomeSkipped, 0)
+		return
+	}
+
+	a.loggerService.LogRuleMatchTrace(rule.AppName, rule.Input.String(), trace)
 
 	// 延迟切换，避免频繁切换
+	debounceStart := time.Now()
 	time.Sleep(time.Duration(config.General.SwitchDelay) * time.Millisecond)
+	a.metricsService.RecordStage(services.StageDebounce, time.Since(debounceStart))
 
 	fmt.Printf("匹配规则: %s -> %s\n", rule.AppName, rule.Input)
 
-	// 切换输入法
-	if err := a.inputService.SwitchInput(rule.Input); err != nil {
-		a.loggerService.LogInputSwitch(rule.AppName, rule.Input, "switch_failed", err)
+	_, err := a.switchToInputs(rule.AppName, rule.Input)
+	a.reportRuleResult(rule, window, err)
+}
+
+// switchToInputs 展开别名后按顺序切换到第一个可用的输入法，返回输入法的显示名称
+func (a *App) switchToInputs(appName string, inputs services.InputList) (string, error) {
+	switchStart := time.Now()
+	inputID, backend, reason, err := a.inputService.SwitchFirstInput(a.matcherService.ResolveInputs(inputs))
+	switchDuration := time.Since(switchStart)
+	a.metricsService.RecordStage(services.StageBackendSwitch, switchDuration)
+	if err != nil {
+		a.metricsService.RecordBackendSwitch(backend, services.OutcomeFailure, switchDuration)
+		a.loggerService.LogInputSwitch(appName, inputs.String(), "switch_failed", err)
 		fmt.Printf("切换输入法失败: %v\n", err)
-	} else {
-		a.loggerService.LogInputSwitch(rule.AppName, rule.Input, "switch_success", nil)
-		fmt.Printf("已切换到输入法: %s\n", rule.Input)
+		return "", err
 	}
+	display := inputDisplayName(a.matcherService.InputLabel(inputs, inputID), inputID)
+	a.loggerService.LogInputSelect(appName, display, reason)
+
+	a.metricsService.RecordBackendSwitch(backend, services.OutcomeSuccess, switchDuration)
+	a.loggerService.LogInputSwitch(appName, display, "switch_success", nil)
+	fmt.Printf("已切换到输入法: %s\n", display)
+	a.setCurrentInput(display)
+	return display, nil
+}
+
+// ruleMetricsName 规则在指标中的显示名称，指标按规则ID统计
+func ruleMetricsName(rule *services.Rule) string {
+	return fmt.Sprintf("%s -> %s", rule.AppName, rule.Input)
+}
+
+// reportRuleResult 报告规则执行结果并记录端到端耗时，规则被暂停或恢复时记录日志并刷新状态栏
+func (a *App) reportRuleResult(rule *services.Rule, window *services.WindowInfo, err error) {
+	outcome := services.OutcomeSuccess
+	if err != nil {
+		outcome = services.OutcomeFailure
+	}
+	var total time.Duration
+	if window != nil && !window.DetectedAt.IsZero() {
+		total = time.Since(window.DetectedAt) + window.DetectDuration
+		a.metricsService.RecordStage(services.StageTotal, total)
+	}
+	a.metricsService.RecordRuleOutcome(rule.ID, ruleMetricsName(rule), outcome, total)
+
+	tripped, recovered := a.matcherService.ReportRuleResult(rule, err)
+	switch {
+	case tripped:
+		message := fmt.Sprintf("规则连续切换失败，已暂停: %s -> %s", rule.AppName, rule.Input)
+		a.loggerService.LogWarn(message)
+		fmt.Println(message)
+		a.notifyStateChange()
+	case recovered:
+		message := fmt.Sprintf("规则已恢复: %s -> %s", rule.AppName, rule.Input)
+		a.loggerService.LogInfo(message)
+		fmt.Println(message)
+		a.notifyStateChange()
+	}
+}
+
+// GetSuspendedRules 获取被熔断暂停的规则
+func (a *App) GetSuspendedRules() []services.Rule {
+	return a.matcherService.GetSuspendedRules()
+}
+
+// inputDisplayName 生成输入法的显示名称，有别名时显示为 "别名 (ID)"
+func inputDisplayName(label, inputID string) string {
+	if label == "" || label == inputID {
+		return inputID
+	}
+	return fmt.Sprintf("%s (%s)", label, inputID)
+}
+
+// SetStateChangeCallback 设置状态变化回调（用于刷新状态栏显示）
+func (a *App) SetStateChangeCallback(callback func()) {
+	a.statusMutex.Lock()
+	defer a.statusMutex.Unlock()
+	a.onStateChange = callback
+}
+
+// notifyStateChange 通知状态栏刷新
+func (a *App) notifyStateChange() {
+	a.statusMutex.RLock()
+	callback := a.onStateChange
+	a.statusMutex.RUnlock()
+
+	if callback != nil {
+		callback()
+	}
+}
+
+// setCurrentInput 记录最近一次切换到的输入法并刷新状态栏
+func (a *App) setCurrentInput(display string) {
+	a.statusMutex.Lock()
+	a.currentInput = display
+	a.statusMutex.Unlock()
+	a.notifyStateChange()
+}
+
+// CurrentInputLabel 获取最近一次自动切换到的输入法显示名称
+func (a *App) CurrentInputLabel() string {
+	a.statusMutex.RLock()
+	defer a.statusMutex.RUnlock()
+	return a.currentInput
 }
 
 // GetActiveWindow 获取当前活动窗口
@@ -146,6 +408,46 @@ func (a *App) GetAvailableInputs() ([]*services.InputMethod, error) {
 	return a.inputService.GetAvailableInputs()
 }
 
+// ValidateConfig 校验配置文件，一次返回所有问题（包括行号和列号）
+func (a *App) ValidateConfig() (*services.ValidationResult, error) {
+	return a.matcherService.ValidateConfig(availableInputIDs(a.inputService))
+}
+
+// LintConfig 检查当前配置中的规则：重复、被遮蔽、目标冲突的规则，空的匹配模式和不可用的输入法
+func (a *App) LintConfig() (*services.LintResult, error) {
+	return a.matcherService.LintConfig(availableInputIDs(a.inputService))
+}
+
+// availableInputIDs 返回系统中已启用输入法的ID，无法获取时（非 macOS）返回 nil，跳过输入法是否可用的检查
+func availableInputIDs(inputService *services.InputService) []string {
+	ids, err := inputService.InstalledInputIDs()
+	if err != nil {
+		return nil
+	}
+	return ids
+}
+
+// GetMetrics 获取当天的切换耗时和结果统计
+func (a *App) GetMetrics() (*services.DailyMetrics, error) {
+	if a.metricsService == nil {
+		return nil, fmt.Errorf("metrics service not initialized")
+	}
+	return a.metricsService.GetMetrics(), nil
+}
+
+// GetDailyMetrics 获取指定日期（YYYY-MM-DD）的统计数据
+func (a *App) GetDailyMetrics(date string) (*services.DailyMetrics, error) {
+	if a.metricsService == nil {
+		return nil, fmt.Errorf("metrics service not initialized")
+	}
+	return a.metricsService.GetDailyMetrics(date)
+}
+
+// GetBackendHealth 获取输入法后端的健康状态
+func (a *App) GetBackendHealth() []services.BackendHealth {
+	return a.inputService.GetBackendHealth()
+}
+
 // SwitchInput 切换输入法
 func (a *App) SwitchInput(inputID string) error {
 	return a.inputService.SwitchInput(inputID)
@@ -167,49 +469,155 @@ func (a *App) SaveConfig(config *services.Config) error {
 	}
 
 	fmt.Println("配置已保存")
+	a.notifyStateChange()
 	return nil
 }
 
-// AddRule 添加规则
-func (a *App) AddRule(rule services.Rule) error {
-	if err := a.matcherService.AddRule(rule); err != nil {
-		return err
+// GetRule 获取指定ID的规则
+func (a *App) GetRule(id string) (*services.Rule, error) {
+	return a.matcherService.GetRule(id)
+}
+
+// AddRule 添加规则，返回分配了ID的规则
+func (a *App) AddRule(rule services.Rule) (*services.Rule, error) {
+	added, err := a.matcherService.AddRule(rule)
+	if err != nil {
+		return nil, err
 	}
 
-	fmt.Printf("已添加规则: %s -> %s\n", rule.AppName, rule.Input)
-	return nil
+	fmt.Printf("已添加规则: [%s] %s -> %s\n", added.ID, added.AppName, added.Input)
+	a.notifyStateChange()
+	return added, nil
 }
 
 // UpdateRule 更新规则
-func (a *App) UpdateRule(index int, rule services.Rule) error {
-	if err := a.matcherService.UpdateRule(index, rule); err != nil {
+func (a *App) UpdateRule(id string, rule services.Rule) error {
+	if err := a.matcherService.UpdateRule(id, rule); err != nil {
 		return err
 	}
 
-	fmt.Printf("已更新规则: %s -> %s\n", rule.AppName, rule.Input)
+	fmt.Printf("已更新规则: [%s] %s -> %s\n", id, rule.AppName, rule.Input)
+	a.notifyStateChange()
 	return nil
 }
 
 // DeleteRule 删除规则
-func (a *App) DeleteRule(index int) error {
-	config := a.matcherService.GetConfig()
-	if config == nil {
-		return fmt.Errorf("config not loaded")
+func (a *App) DeleteRule(id string) error {
+	rule, err := a.matcherService.GetRule(id)
+	if err != nil {
+		return err
 	}
 
-	if index < 0 || index >= len(config.Rules) {
-		return fmt.Errorf("规则索引超出范围")
+	if err := a.matcherService.DeleteRule(id); err != nil {
+		return err
+	}
+
+	fmt.Printf("已删除规则: [%s] %s -> %s\n", id, rule.AppName, rule.Input)
+	a.notifyStateChange()
+	return nil
+}
+
+// profileDisplayName 返回配置方案的显示名称
+func profileDisplayName(name string) string {
+	if name == "" {
+		return "无"
 	}
+	return name
+}
+
+// GetRuleSources 获取 rules.d 目录中加载的只读规则文件
+func (a *App) GetRuleSources() []services.RuleSource {
+	return a.matcherService.GetRuleSources()
+}
 
-	rule := config.Rules[index]
-	if err := a.matcherService.DeleteRule(index); err != nil {
+// GetProfiles 获取所有配置方案及其状态
+func (a *App) GetProfiles() []services.ProfileStatus {
+	return a.matcherService.GetProfiles()
+}
+
+// SetActiveProfile 手动选择配置方案，传入空字符串表示恢复自动选择
+func (a *App) SetActiveProfile(name string) error {
+	if err := a.matcherService.SetActiveProfile(name); err != nil {
+		a.loggerService.LogError(fmt.Sprintf("切换配置方案失败: %v", err))
 		return err
 	}
 
-	fmt.Printf("已删除规则: %s -> %s\n", rule.AppName, rule.Input)
+	profile, _ := a.matcherService.ActiveProfile()
+	if name == "" {
+		a.loggerService.LogInfo(fmt.Sprintf("配置方案恢复自动选择，当前: %s", profileDisplayName(profile)))
+	} else {
+		a.loggerService.LogInfo(fmt.Sprintf("手动选择配置方案: %s", name))
+	}
+	a.notifyStateChange()
 	return nil
 }
 
+// AddRuleToProfile 向配置方案添加规则，返回分配了ID的规则
+func (a *App) AddRuleToProfile(profile string, rule services.Rule) (*services.Rule, error) {
+	added, err := a.matcherService.AddRuleToProfile(profile, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("已添加规则: [%s] %s -> %s（配置方案 %s）\n", added.ID, added.AppName, added.Input, profile)
+	a.notifyStateChange()
+	return added, nil
+}
+
+// ListBackups 列出配置文件的历史备份，最新的在前
+func (a *App) ListBackups() ([]services.BackupInfo, error) {
+	return a.matcherService.ListBackups()
+}
+
+// RestoreBackup 将配置恢复为指定的备份，恢复前的配置同样会被备份
+func (a *App) RestoreBackup(name string) error {
+	if err := a.matcherService.RestoreBackup(name); err != nil {
+		errorMsg := fmt.Sprintf("恢复配置备份失败: %v", err)
+		a.loggerService.LogError(errorMsg)
+		fmt.Printf("%s\n", errorMsg)
+		return err
+	}
+
+	config := a.matcherService.GetConfig()
+	if config != nil {
+		a.loggerService.SetLogging(config.General.EnableLogging)
+	}
+
+	a.notifyStateChange()
+
+	successMsg := fmt.Sprintf("已恢复配置备份: %s", name)
+	a.loggerService.LogInfo(successMsg)
+	fmt.Printf("%s\n", successMsg)
+	return nil
+}
+
+// ExplainMatch 解释窗口的规则匹配过程，window 为空时使用当前活动窗口
+func (a *App) ExplainMatch(window *services.WindowInfo) (*services.MatchExplanation, error) {
+	if window == nil {
+		activeWindow, err := a.windowService.GetActiveWindow()
+		if err != nil {
+			return nil, err
+		}
+		window = activeWindow
+	}
+
+	explanation := a.matcherService.Explain(window)
+	if explanation == nil {
+		return nil, fmt.Errorf("no window to explain")
+	}
+
+	// 恢复模式下补充该应用记录的输入法
+	if explanation.Fallback != nil && explanation.Fallback.Mode == services.FallbackRestore {
+		if inputID, exists := a.inputService.RememberedInput(window.AppName); exists {
+			explanation.Fallback.Input = services.InputList{inputID}
+			explanation.Reason = fmt.Sprintf("没有匹配的规则，恢复该应用上次使用的输入法 %s", inputID)
+		} else {
+			explanation.Reason = "没有匹配的规则，该应用没有输入法记录，保持当前输入法"
+		}
+	}
+	return explanation, nil
+}
+
 // TestRule 测试规则
 func (a *App) TestRule(rule services.Rule) (bool, *services.WindowInfo, error) {
 	window, err := a.windowService.GetActiveWindow()
@@ -218,7 +626,13 @@ func (a *App) TestRule(rule services.Rule) (bool, *services.WindowInfo, error) {
 	}
 
 	matched := a.matcherService.MatchWindow(window)
-	if matched != nil && matched.AppName == rule.AppName {
+	if matched == nil {
+		return false, window, nil
+	}
+	if rule.ID != "" {
+		return matched.ID == rule.ID, window, nil
+	}
+	if matched.AppName == rule.AppName {
 		return true, window, nil
 	}
 
@@ -270,6 +684,15 @@ func (a *App) onShutdown(ctx context.Context) {
 	if a.windowService != nil {
 		a.windowService.StopMonitoring()
 	}
+	if a.inputService != nil {
+		a.inputService.StopHealthChecks()
+	}
+	if a.matcherService != nil {
+		a.matcherService.StopWatching()
+	}
+	if a.metricsService != nil {
+		a.metricsService.Stop()
+	}
 }
 
 
@@ -313,14 +736,8 @@ func (a *App) ClearLogs() error {
 
 // openConfigFile 使用系统默认编辑器打开配置文件
 func (a *App) openConfigFile() {
-	// 获取用户家目录
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		fmt.Printf("无法获取用户家目录: %v\n", err)
-		return
-	}
-
-	configPath := filepath.Join(homeDir, ".switch-input", "config.json")
+	// 打开当前使用的配置文件（config.json、config.yaml 或 config.toml）
+	configPath := a.matcherService.ConfigPath()
 
 	var cmd *exec.Cmd
 	switch stdruntime.GOOS {
@@ -335,13 +752,46 @@ func (a *App) openConfigFile() {
 		cmd = exec.Command("xdg-open", configPath)
 	}
 
-	err = cmd.Run()
-	if err != nil {
+	if err := cmd.Run(); err != nil {
 		a.loggerService.LogError(fmt.Sprintf("无法打开配置文件: %v", err))
 		fmt.Printf("无法打开配置文件: %v\n", err)
 	}
 }
 
+// onConfigReload 配置文件变化被自动重新加载后调用
+func (a *App) onConfigReload(err error) {
+	if err != nil {
+		errorMsg := fmt.Sprintf("配置文件无效，继续使用之前的配置: %v", err)
+		a.loggerService.LogError(errorMsg)
+		fmt.Printf("%s\n", errorMsg)
+		return
+	}
+
+	config := a.matcherService.GetConfig()
+	if config != nil {
+		a.loggerService.SetLogging(config.General.EnableLogging)
+	}
+
+	a.notifyStateChange()
+
+	successMsg := "检测到配置文件变化，已自动重新加载"
+	a.loggerService.LogInfo(successMsg)
+	fmt.Printf("%s\n", successMsg)
+}
+
+// onConfigNotice 记录加载配置过程中的提示
+func (a *App) onConfigNotice(level services.LogLevel, message string) {
+	switch level {
+	case services.LogLevelError:
+		a.loggerService.LogError(message)
+	case services.LogLevelWarn:
+		a.loggerService.LogWarn(message)
+	default:
+		a.loggerService.LogInfo(message)
+	}
+	fmt.Println(message)
+}
+
 // reloadConfig 重新加载配置文件
 func (a *App) reloadConfig() {
 	fmt.Println("正在重新加载配置文件...")
@@ -360,6 +810,8 @@ func (a *App) reloadConfig() {
 		a.loggerService.SetLogging(config.General.EnableLogging)
 	}
 
+	a.notifyStateChange()
+
 	successMsg := "配置文件重新加载成功"
 	a.loggerService.LogInfo(successMsg)
 	fmt.Printf("%s\n", successMsg)
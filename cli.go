@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"switch-input/services"
+)
+
+// cliUsage 命令行用法说明
+const cliUsage = `用法:
+  switch-input [-配置项 值 ...]     启动状态栏应用，参数覆盖对应的配置项，例如 -log-level debug
+  switch-input validate [-json] [配置文件]  校验配置文件，默认为用户配置目录下的配置文件
+  switch-input lint [-json] [配置文件]      检查规则：重复、被遮蔽、目标冲突的规则，空的匹配模式和不可用的输入法
+`
+
+// runCommand 执行命令行子命令，返回进程退出码
+func runCommand(args []string) int {
+	switch args[0] {
+	case "validate":
+		return runValidate(args[1:])
+	case "lint":
+		return runLint(args[1:])
+	case "help":
+		fmt.Print(cliUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "未知命令: %s\n\n%s", args[0], cliUsage)
+		return 2
+	}
+}
+
+// parseAppFlags 解析启动状态栏应用时的命令行参数，返回配置项位置 -> 参数值
+// 解析失败时 flag 包已输出错误和用法
+func parseAppFlags(args []string) (map[string]string, error) {
+	flags := flag.NewFlagSet("switch-input", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), cliUsage)
+		fmt.Fprintln(flags.Output(), "\n配置项参数:")
+		flags.PrintDefaults()
+	}
+	values := services.RegisterOverrideFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		err := fmt.Errorf("unexpected argument: %s", flags.Arg(0))
+		fmt.Fprintln(flags.Output(), err)
+		return nil, err
+	}
+	return values(), nil
+}
+
+// runValidate 校验配置文件并输出所有问题，配置无效时返回 1
+func runValidate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	jsonOutput := flags.Bool("json", false, "以 JSON 格式输出校验结果")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	configPath := services.FindConfigFile(defaultConfigDir())
+	if flags.NArg() > 0 {
+		configPath = flags.Arg(0)
+	}
+
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "无法读取配置文件: %v\n", err)
+		return 2
+	}
+
+	result := services.ValidateConfigFile(configPath, data, availableInputIDs(services.NewInputService()))
+
+	if *jsonOutput {
+		output, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "无法输出校验结果: %v\n", err)
+			return 2
+		}
+		fmt.Println(string(output))
+	} else {
+		for _, issue := range result.Issues {
+			if issue.Line > 0 {
+				fmt.Printf("%s:%s\n", configPath, issue)
+			} else {
+				fmt.Printf("%s: %s\n", configPath, issue)
+			}
+		}
+		if result.Valid {
+			fmt.Printf("%s: 配置有效\n", configPath)
+		}
+	}
+
+	if !result.Valid {
+		return 1
+	}
+	return 0
+}
+
+// runLint 检查配置文件中的规则，发现问题时退出码为 1，无法检查时为 2
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	jsonOutput := flags.Bool("json", false, "以 JSON 格式输出检查结果")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	configPath := services.FindConfigFile(defaultConfigDir())
+	if flags.NArg() > 0 {
+		configPath = flags.Arg(0)
+	}
+
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "无法读取配置文件: %v\n", err)
+		return 2
+	}
+
+	result, err := services.LintConfigFile(configPath, data, availableInputIDs(services.NewInputService()))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: 配置无效，请先运行 validate 命令: %v\n", configPath, err)
+		return 2
+	}
+
+	if *jsonOutput {
+		output, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "无法输出检查结果: %v\n", err)
+			return 2
+		}
+		fmt.Println(string(output))
+	} else {
+		for _, issue := range result.Issues {
+			fmt.Printf("%s: %s\n", configPath, issue)
+		}
+		if len(result.Issues) == 0 {
+			fmt.Printf("%s: 没有发现问题\n", configPath)
+		}
+	}
+
+	if len(result.Issues) > 0 {
+		return 1
+	}
+	return 0
+}
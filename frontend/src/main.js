@@ -232,7 +232,7 @@ function renderRules() {
                     <strong>输入法:</strong> ${getInputName(rule.input)}
                 </div>
                 <div class="rule-priority">
-                    <strong>优先级:</strong> ${rule.priority}
+                    <strong>优先级:</strong> ${rule.priority || 0}
                 </div>
                 <div class="rule-enabled">
                     <label>
@@ -405,7 +405,7 @@ function editRule(index) {
     document.getElementById('ruleAppName').value = rule.app;
     document.getElementById('ruleWindowName').value = rule.window || '';
     document.getElementById('ruleInput').value = rule.input;
-    document.getElementById('rulePriority').value = rule.priority;
+    document.getElementById('rulePriority').value = rule.priority || 0;
     document.getElementById('ruleEnabled').checked = rule.enabled;
 
     // 修改对话框为编辑模式
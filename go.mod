@@ -2,7 +2,14 @@ module switch-input
 
 go 1.23
 
-require github.com/getlantern/systray v1.2.2
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/fsnotify/fsnotify v1.8.0
+	github.com/getlantern/systray v1.2.2
+	github.com/pmezard/go-difflib v1.0.0
+	github.com/tailscale/hujson v0.0.0-20250605163823-992244df8c5a
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/getlantern/context v0.0.0-20190109183933-c447772a6520 // indirect
@@ -2,7 +2,12 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"strings"
+	"sync"
 
 	"github.com/getlantern/systray"
 	"github.com/getlantern/systray/example/icon"
@@ -11,8 +16,23 @@ import (
 var globalApp *App // 全局应用实例
 
 func main() {
+	// 命令行子命令，例如 switch-input validate
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
+	// 其余参数覆盖配置项，例如 switch-input -log-level debug
+	flagOverrides, err := parseAppFlags(os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+
 	// Create an instance of the app structure
 	globalApp = NewApp()
+	globalApp.flagOverrides = flagOverrides
 
 	// 创建上下文用于应用服务
 	ctx := context.Background()
@@ -33,12 +53,53 @@ func onReady() {
 	systray.SetTitle("")
 	systray.SetTooltip("输入法自动切换服务")
 
+	// 当前输入法状态（仅用于显示）
+	mStatus := systray.AddMenuItem("当前输入法: -", "最近一次自动切换的输入法")
+	mStatus.Disable()
+
+	// 被暂停的规则（仅用于显示）
+	mSuspended := systray.AddMenuItem("暂停的规则: 无", "连续切换失败而被暂停的规则")
+	mSuspended.Disable()
+
+	// 配置方案子菜单
+	refreshProfiles := addProfileMenu()
+
+	systray.AddSeparator()
+
 	// 打开配置文件菜单项
 	mOpenConfig := systray.AddMenuItem("打开配置文件", "使用系统默认编辑器打开配置文件")
 
 	// 重新加载配置文件菜单项
 	mReloadConfig := systray.AddMenuItem("重新加载配置", "重新加载配置文件")
 
+	// 配置备份子菜单
+	refreshBackups := addBackupMenu()
+
+	refreshTray := func() {
+		refreshProfiles()
+		refreshBackups()
+
+		if label := globalApp.CurrentInputLabel(); label != "" {
+			mStatus.SetTitle(fmt.Sprintf("当前输入法: %s", label))
+		}
+
+		suspended := globalApp.GetSuspendedRules()
+		if len(suspended) == 0 {
+			mSuspended.SetTitle("暂停的规则: 无")
+			mSuspended.SetTooltip("连续切换失败而被暂停的规则")
+			return
+		}
+		var names, details []string
+		for _, rule := range suspended {
+			names = append(names, rule.AppName)
+			details = append(details, fmt.Sprintf("%s -> %s: %s", rule.AppName, rule.Input, rule.Breaker.LastError))
+		}
+		mSuspended.SetTitle(fmt.Sprintf("暂停的规则: %s", strings.Join(names, "; ")))
+		mSuspended.SetTooltip(strings.Join(details, "\n"))
+	}
+	globalApp.SetStateChangeCallback(refreshTray)
+	refreshTray()
+
 	// 分隔线
 	systray.AddSeparator()
 
@@ -61,6 +122,133 @@ func onReady() {
 	}()
 }
 
+// addProfileMenu 添加配置方案子菜单，返回根据当前配置刷新菜单的函数
+// 配置方案可能在重新加载配置后增减，菜单项按名称动态创建，已删除的方案隐藏
+func addProfileMenu() func() {
+	mProfile := systray.AddMenuItem("配置方案: 无", "选择启用的规则配置方案")
+	mAuto := mProfile.AddSubMenuItemCheckbox("自动", "按时间或主机名自动选择配置方案", true)
+	go func() {
+		for range mAuto.ClickedCh {
+			globalApp.SetActiveProfile("")
+		}
+	}()
+
+	var mutex sync.Mutex
+	items := make(map[string]*systray.MenuItem)
+
+	return func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		profiles := globalApp.GetProfiles()
+		if len(profiles) == 0 {
+			mProfile.Hide()
+			return
+		}
+		mProfile.Show()
+
+		title := "配置方案: 无"
+		manual := false
+		present := make(map[string]bool)
+		for _, profile := range profiles {
+			present[profile.Name] = true
+
+			item, exists := items[profile.Name]
+			if !exists {
+				name := profile.Name
+				item = mProfile.AddSubMenuItemCheckbox(name, fmt.Sprintf("启用配置方案 %s", name), false)
+				items[name] = item
+				go func() {
+					for range item.ClickedCh {
+						globalApp.SetActiveProfile(name)
+					}
+				}()
+			}
+			item.Show()
+
+			if profile.Active {
+				title = fmt.Sprintf("配置方案: %s", profile.Name)
+				manual = profile.Manual
+			}
+			if profile.Active && profile.Manual {
+				item.Check()
+			} else {
+				item.Uncheck()
+			}
+		}
+
+		for name, item := range items {
+			if !present[name] {
+				item.Hide()
+			}
+		}
+
+		if manual {
+			mAuto.Uncheck()
+		} else {
+			mAuto.Check()
+			title += "（自动）"
+		}
+		mProfile.SetTitle(title)
+	}
+}
+
+// maxTrayBackups 状态栏中最多列出的配置备份数量
+const maxTrayBackups = 10
+
+// addBackupMenu 添加恢复配置备份的子菜单，返回根据备份目录刷新菜单的函数
+// 子菜单预先创建固定数量的菜单项，刷新时更新标题并隐藏多余的项
+func addBackupMenu() func() {
+	mBackups := systray.AddMenuItem("恢复配置备份", "将配置恢复为之前保存的版本")
+
+	var mutex sync.Mutex
+	names := make([]string, maxTrayBackups)
+	items := make([]*systray.MenuItem, maxTrayBackups)
+	for i := range items {
+		items[i] = mBackups.AddSubMenuItem("", "恢复此备份，当前配置会先被备份")
+		items[i].Hide()
+
+		index := i
+		go func() {
+			for range items[index].ClickedCh {
+				mutex.Lock()
+				name := names[index]
+				mutex.Unlock()
+				if name != "" {
+					globalApp.RestoreBackup(name)
+				}
+			}
+		}()
+	}
+
+	return func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		backups, err := globalApp.ListBackups()
+		if err != nil || len(backups) == 0 {
+			mBackups.Disable()
+		} else {
+			mBackups.Enable()
+		}
+
+		for i, item := range items {
+			if i >= len(backups) {
+				names[i] = ""
+				item.Hide()
+				continue
+			}
+			names[i] = backups[i].Name
+			title := backups[i].Time.Format("2006-01-02 15:04:05")
+			if backups[i].PreMigration {
+				title += fmt.Sprintf("（升级前的版本 %d）", backups[i].FromVersion)
+			}
+			item.SetTitle(title)
+			item.Show()
+		}
+	}
+}
+
 // onExit 状态栏退出时调用
 func onExit() {
 	// 清理资源
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultBackupCount 默认保留的配置备份数量
+const defaultBackupCount = 10
+
+// backupTimeFormat 备份文件名中的时间格式，按文件名排序即为时间顺序
+const backupTimeFormat = "20060102-150405.000"
+
+// BackupInfo 配置备份信息
+type BackupInfo struct {
+	Name         string    `json:"name"`                   // 备份文件名
+	Time         time.Time `json:"time"`                   // 备份时间
+	Size         int64     `json:"size"`                   // 文件大小（字节）
+	PreMigration bool      `json:"preMigration,omitempty"` // 是否为升级配置版本前保存的原始文件，不参与备份轮换
+	FromVersion  int       `json:"fromVersion,omitempty"`  // 升级前的配置版本
+}
+
+// writeFileAtomic 原子地写入文件：先写入同目录的临时文件并同步到磁盘，再重命名覆盖目标文件
+// 写入过程中崩溃时目标文件保持原样，不会出现只写了一半的文件
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// 同步目录，确保重命名本身也已落盘；部分平台不支持同步目录，忽略错误
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// backupDir 返回配置备份目录
+func (ms *MatcherService) backupDir() string {
+	return filepath.Join(filepath.Dir(ms.configPath), "backups")
+}
+
+// backupName 生成备份文件名，例如 config-20250101-120000.000.json
+func (ms *MatcherService) backupName(t time.Time) string {
+	base := filepath.Base(ms.configPath)
+	ext := filepath.Ext(base)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(base, ext), t.Format(backupTimeFormat), ext)
+}
+
+// parseBackupName 从备份文件名中解析备份信息
+// 普通备份为 config-<时间>.json；升级前的备份为 config-v<版本>.json 或 config-v<版本>-<时间>.json，
+// 文件名中没有时间时 Time 为零值，由调用方使用文件的修改时间
+func (ms *MatcherService) parseBackupName(name string) (BackupInfo, bool) {
+	base := filepath.Base(ms.configPath)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+		return BackupInfo{}, false
+	}
+	rest := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+	info := BackupInfo{Name: name}
+
+	if strings.HasPrefix(rest, "v") {
+		version, stamp, hasStamp := strings.Cut(rest[1:], "-")
+		n, err := strconv.Atoi(version)
+		if err != nil || n < 0 || n >= currentConfigVersion {
+			return BackupInfo{}, false
+		}
+		info.PreMigration = true
+		info.FromVersion = n
+		if !hasStamp {
+			return info, true
+		}
+		rest = stamp
+	}
+
+	t, err := time.ParseInLocation(backupTimeFormat, rest, time.Local)
+	if err != nil {
+		return BackupInfo{}, false
+	}
+	info.Time = t
+	return info, true
+}
+
+// writeConfigFileLocked 备份当前配置文件后原子地写入新内容，只保留最近 keep 个备份，调用方需持有写锁
+func (ms *MatcherService) writeConfigFileLocked(data []byte, keep int) error {
+	// 确保目录存在
+	dir := filepath.Dir(ms.configPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+
+	if err := ms.backupConfigLocked(keep); err != nil {
+		return fmt.Errorf("failed to back up config file: %v", err)
+	}
+
+	if err := writeFileAtomic(ms.configPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+	return nil
+}
+
+// backupConfigLocked 将当前配置文件复制到备份目录，并删除超出数量的旧备份
+func (ms *MatcherService) backupConfigLocked(keep int) error {
+	current, err := ioutil.ReadFile(ms.configPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(ms.backupDir(), 0755); err != nil {
+		return err
+	}
+	// 连续保存时避免备份文件名重复
+	t := time.Now()
+	path := filepath.Join(ms.backupDir(), ms.backupName(t))
+	for {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		t = t.Add(time.Millisecond)
+		path = filepath.Join(ms.backupDir(), ms.backupName(t))
+	}
+	if err := writeFileAtomic(path, current, 0644); err != nil {
+		return err
+	}
+
+	if keep <= 0 {
+		keep = defaultBackupCount
+	}
+	backups, err := ms.listBackups()
+	if err != nil {
+		return err
+	}
+	// 升级前的原始文件不参与轮换
+	kept := 0
+	for _, backup := range backups {
+		if backup.PreMigration {
+			continue
+		}
+		if kept++; kept > keep {
+			os.Remove(filepath.Join(ms.backupDir(), backup.Name))
+		}
+	}
+	return nil
+}
+
+// listBackups 列出备份目录中的配置备份，最新的在前
+func (ms *MatcherService) listBackups() ([]BackupInfo, error) {
+	entries, err := ioutil.ReadDir(ms.backupDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %v", err)
+	}
+
+	var backups []BackupInfo
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		backup, ok := ms.parseBackupName(entry.Name())
+		if !ok {
+			continue
+		}
+		if backup.Time.IsZero() {
+			backup.Time = entry.ModTime()
+		}
+		backup.Size = entry.Size()
+		backups = append(backups, backup)
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool {
+		if !backups[i].Time.Equal(backups[j].Time) {
+			return backups[i].Time.After(backups[j].Time)
+		}
+		return backups[i].Name > backups[j].Name
+	})
+	return backups, nil
+}
+
+// ListBackups 列出配置备份（包括升级配置版本前保存的原始文件），最新的在前
+func (ms *MatcherService) ListBackups() ([]BackupInfo, error) {
+	ms.ruleMutex.RLock()
+	defer ms.ruleMutex.RUnlock()
+
+	return ms.listBackups()
+}
+
+// RestoreBackup 将指定的备份恢复为当前配置
+// 备份需要通过校验才会恢复；恢复前的配置文件同样会被备份，可以再次恢复
+func (ms *MatcherService) RestoreBackup(name string) error {
+	ms.ruleMutex.Lock()
+	defer ms.ruleMutex.Unlock()
+
+	if name != filepath.Base(name) {
+		return fmt.Errorf("invalid backup name: %s", name)
+	}
+	if _, ok := ms.parseBackupName(name); !ok {
+		return fmt.Errorf("invalid backup name: %s", name)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(ms.backupDir(), name))
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %v", err)
+	}
+
+	sources, err := loadRuleSources(ms.ruleSourceDir())
+	if err != nil {
+		return err
+	}
+
+	// 旧版本的备份先升级到当前版本，升级后的配置直接保存
+	config, compiled, version, err := ms.loadConfigDataLocked(data, sources)
+	if err != nil {
+		return fmt.Errorf("backup %s is invalid: %v", name, err)
+	}
+	if version < currentConfigVersion {
+		// 恢复备份有意覆盖当前的配置文件，以当前文件为基础保存
+		current, err := ioutil.ReadFile(ms.configPath)
+		config.Revision = fileRevision(current, err)
+		assignRuleIDs(config)
+		return ms.saveConfigLocked(config)
+	}
+
+	keep := defaultBackupCount
+	if snapshot := ms.current(); snapshot != nil {
+		keep = snapshot.config.General.BackupCount
+	}
+	if err := ms.writeConfigFileLocked(data, keep); err != nil {
+		return err
+	}
+
+	return ms.useConfigLocked(config, compiled, data, false, true)
+}
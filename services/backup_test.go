@@ -0,0 +1,165 @@
+package services
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(path, []byte("new"), 0644); err != nil {
+		t.Fatalf("writeFileAtomic: %v", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil || string(data) != "new" {
+		t.Errorf("file content = %q, %v, want new", data, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("file mode = %v, %v, want 0644", info.Mode().Perm(), err)
+	}
+
+	// 不留下临时文件
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("directory contains %v, want only config.json", names)
+	}
+
+	// 目录不存在时返回错误，不创建文件
+	if err := writeFileAtomic(filepath.Join(dir, "missing", "config.json"), []byte("x"), 0644); err == nil {
+		t.Error("writeFileAtomic succeeded in a missing directory")
+	}
+}
+
+func TestSaveKeepsBackups(t *testing.T) {
+	ms, err := loadTestConfig(t, map[string]interface{}{
+		"version": currentConfigVersion,
+		"general": map[string]interface{}{"backupCount": 2},
+		"rules":   []Rule{testRule("terminal", "Terminal", MatchExact, 1)},
+	})
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	var saved [][]byte
+	for _, app := range []string{"Safari", "Mail", "Notes", "Music"} {
+		before, err := ioutil.ReadFile(ms.configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved = append(saved, before)
+		if _, err := ms.AddRule(Rule{AppName: app, Input: InputList{"com.apple.keylayout.ABC"}, Enabled: true}); err != nil {
+			t.Fatalf("AddRule(%s): %v", app, err)
+		}
+	}
+
+	backups, err := ms.ListBackups()
+	if err != nil {
+		t.Fatalf("ListBackups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("got %d backups, want 2: %+v", len(backups), backups)
+	}
+	if !backups[0].Time.After(backups[1].Time) {
+		t.Errorf("backups are not sorted newest first: %+v", backups)
+	}
+
+	// 最新的备份是最后一次保存之前的配置文件
+	for i, backup := range backups {
+		data, err := ioutil.ReadFile(filepath.Join(ms.backupDir(), backup.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := saved[len(saved)-1-i]; !bytes.Equal(data, want) {
+			t.Errorf("backup %s differs from the config before save %d", backup.Name, len(saved)-i)
+		}
+		if backup.Size != int64(len(data)) {
+			t.Errorf("backup %s size = %d, want %d", backup.Name, backup.Size, len(data))
+		}
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	ms := newTestMatcher(t, []Rule{testRule("terminal", "Terminal", MatchExact, 1)})
+	original, err := ioutil.ReadFile(ms.configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ms.AddRule(Rule{AppName: "Safari", Input: InputList{"com.apple.keylayout.ABC"}, Enabled: true}); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+
+	backups, err := ms.ListBackups()
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("ListBackups() = %+v, %v, want one backup", backups, err)
+	}
+	if err := ms.RestoreBackup(backups[0].Name); err != nil {
+		t.Fatalf("RestoreBackup: %v", err)
+	}
+
+	restored, err := ioutil.ReadFile(ms.configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(restored, original) {
+		t.Errorf("restored config differs from the backup:\n%s", restored)
+	}
+	if rule := ms.MatchWindow(&WindowInfo{AppName: "Safari"}); rule != nil {
+		t.Errorf("rule added after the backup still matches: %+v", rule)
+	}
+
+	// 恢复前的配置文件同样被备份
+	if backups, _ := ms.ListBackups(); len(backups) != 2 {
+		t.Errorf("got %d backups after restoring, want 2", len(backups))
+	}
+}
+
+func TestRestoreBackupRejectsInvalidBackups(t *testing.T) {
+	ms := newTestMatcher(t, []Rule{testRule("terminal", "Terminal", MatchExact, 1)})
+	if err := os.MkdirAll(ms.backupDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	invalid := ms.backupName(ms.currentTime())
+	if err := ioutil.WriteFile(filepath.Join(ms.backupDir(), invalid), []byte(`{"version": 1, "rules": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	current, err := ioutil.ReadFile(ms.configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		wantErr string
+	}{
+		{"../config.json", "invalid backup name"},
+		{"notes.txt", "invalid backup name"},
+		{"config-20240101-120000.000.json", "failed to read backup"},
+		{invalid, "is invalid"},
+	}
+	for _, tt := range tests {
+		if err := ms.RestoreBackup(tt.name); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("RestoreBackup(%q) error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// 配置文件保持不变
+	if data, _ := ioutil.ReadFile(ms.configPath); !bytes.Equal(data, current) {
+		t.Error("config file changed after failed restores")
+	}
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// RuleBreakerState 规则熔断状态
+type RuleBreakerState struct {
+	Suspended           bool      `json:"suspended"`           // 是否已暂停
+	ConsecutiveFailures int       `json:"consecutiveFailures"` // 连续失败次数
+	LastError           string    `json:"lastError,omitempty"` // 最后一次错误
+	RetryAt             time.Time `json:"retryAt,omitempty"`   // 下次重试时间
+}
+
+// breakerEntry 单条规则的熔断记录
+type breakerEntry struct {
+	failures  int
+	trips     int // 连续熔断次数，用于计算指数退避
+	lastError string
+	suspended bool
+	probing   bool // 暂停中的规则已放行一次试探执行，等待记录结果
+	retryAt   time.Time
+}
+
+// CircuitBreaker 规则熔断器
+// 规则连续失败达到阈值后暂停，按指数退避时间重试，重试成功后恢复
+type CircuitBreaker struct {
+	threshold   int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	entries     map[string]*breakerEntry
+	mutex       sync.Mutex
+	now         func() time.Time
+}
+
+// NewCircuitBreaker 创建新的熔断器
+func NewCircuitBreaker(threshold int, baseBackoff, maxBackoff time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold:   threshold,
+		baseBackoff: baseBackoff,
+		maxBackoff:  maxBackoff,
+		entries:     make(map[string]*breakerEntry),
+		now:         time.Now,
+	}
+}
+
+// Configure 更新熔断阈值和退避时间
+func (cb *CircuitBreaker) Configure(threshold int, baseBackoff time.Duration) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.threshold = threshold
+	cb.baseBackoff = baseBackoff
+}
+
+// Allow 判断规则当前是否允许执行
+// 暂停中的规则在到达重试时间后允许试探执行一次，记录试探结果之前的其他调用都不允许执行
+func (cb *CircuitBreaker) Allow(key string) bool {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	entry, exists := cb.entries[key]
+	if !exists || !entry.suspended {
+		return true
+	}
+	if entry.probing || cb.now().Before(entry.retryAt) {
+		return false
+	}
+	entry.probing = true
+	return true
+}
+
+// RecordSuccess 记录执行成功，返回规则是否从暂停状态恢复
+func (cb *CircuitBreaker) RecordSuccess(key string) bool {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	entry, exists := cb.entries[key]
+	if !exists {
+		return false
+	}
+	delete(cb.entries, key)
+	return entry.suspended
+}
+
+// RecordFailure 记录执行失败，返回本次失败是否触发了熔断
+func (cb *CircuitBreaker) RecordFailure(key string, err error) bool {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	entry, exists := cb.entries[key]
+	if !exists {
+		entry = &breakerEntry{}
+		cb.entries[key] = entry
+	}
+
+	entry.failures++
+	if err != nil {
+		entry.lastError = err.Error()
+	}
+
+	// 暂停中的试探执行失败，或连续失败达到阈值时熔断
+	if !entry.suspended && entry.failures < cb.threshold {
+		return false
+	}
+
+	entry.trips++
+	entry.suspended = true
+	entry.probing = false
+	entry.retryAt = cb.now().Add(cb.backoff(entry.trips))
+	return true
+}
+
+// backoff 计算第 trips 次熔断的退避时间
+func (cb *CircuitBreaker) backoff(trips int) time.Duration {
+	backoff := cb.baseBackoff
+	for i := 1; i < trips && backoff < cb.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > cb.maxBackoff {
+		backoff = cb.maxBackoff
+	}
+	return backoff
+}
+
+// State 获取规则的熔断状态，没有失败记录时返回 nil
+func (cb *CircuitBreaker) State(key string) *RuleBreakerState {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	entry, exists := cb.entries[key]
+	if !exists {
+		return nil
+	}
+	return &RuleBreakerState{
+		Suspended:           entry.suspended,
+		ConsecutiveFailures: entry.failures,
+		LastError:           entry.lastError,
+		RetryAt:             entry.retryAt,
+	}
+}
+
+// Reset 清空所有熔断记录
+func (cb *CircuitBreaker) Reset() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.entries = make(map[string]*breakerEntry)
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newTestBreaker 创建使用可控时钟的熔断器
+func newTestBreaker(threshold int) (*CircuitBreaker, *time.Time) {
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	cb := NewCircuitBreaker(threshold, time.Minute, 4*time.Minute)
+	cb.now = func() time.Time { return now }
+	return cb, &now
+}
+
+func TestCircuitBreakerSuspendAndProbe(t *testing.T) {
+	cb, now := newTestBreaker(3)
+	errSwitch := fmt.Errorf("switch failed")
+
+	// 连续失败达到阈值后暂停
+	for i := 1; i < 3; i++ {
+		if cb.RecordFailure("rule", errSwitch) {
+			t.Fatalf("failure %d tripped the breaker, threshold is 3", i)
+		}
+		if !cb.Allow("rule") {
+			t.Fatalf("rule not allowed after %d failures", i)
+		}
+	}
+	if !cb.RecordFailure("rule", errSwitch) {
+		t.Fatal("third failure did not trip the breaker")
+	}
+	state := cb.State("rule")
+	if state == nil || !state.Suspended || state.ConsecutiveFailures != 3 || state.LastError != errSwitch.Error() {
+		t.Fatalf("State after tripping = %+v", state)
+	}
+	if !state.RetryAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("RetryAt = %v, want %v", state.RetryAt, now.Add(time.Minute))
+	}
+	if cb.Allow("rule") {
+		t.Error("suspended rule allowed before the retry time")
+	}
+
+	// 到达重试时间后只放行一次试探执行
+	*now = now.Add(time.Minute)
+	if !cb.Allow("rule") {
+		t.Fatal("probe not allowed at the retry time")
+	}
+	if cb.Allow("rule") {
+		t.Error("second call allowed while the probe is pending")
+	}
+
+	// 试探失败后重新暂停，退避时间加倍
+	if !cb.RecordFailure("rule", errSwitch) {
+		t.Error("failed probe did not suspend the rule again")
+	}
+	if state := cb.State("rule"); !state.RetryAt.Equal(now.Add(2 * time.Minute)) {
+		t.Errorf("RetryAt after a failed probe = %v, want %v", state.RetryAt, now.Add(2*time.Minute))
+	}
+
+	// 试探成功后恢复
+	*now = now.Add(2 * time.Minute)
+	if !cb.Allow("rule") {
+		t.Fatal("probe not allowed at the second retry time")
+	}
+	if !cb.RecordSuccess("rule") {
+		t.Error("successful probe did not report recovery")
+	}
+	if state := cb.State("rule"); state != nil {
+		t.Errorf("State after recovery = %+v, want nil", state)
+	}
+	if !cb.Allow("rule") {
+		t.Error("recovered rule not allowed")
+	}
+}
+
+func TestCircuitBreakerBackoff(t *testing.T) {
+	cb, _ := newTestBreaker(1)
+	tests := []struct {
+		trips int
+		want  time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{10, 4 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := cb.backoff(tt.trips); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.trips, got, tt.want)
+		}
+	}
+}
+
+func TestCircuitBreakerSuccessResetsFailures(t *testing.T) {
+	cb, _ := newTestBreaker(2)
+	cb.RecordFailure("rule", fmt.Errorf("switch failed"))
+	if cb.RecordSuccess("rule") {
+		t.Error("RecordSuccess reported recovery for a rule that was not suspended")
+	}
+	if cb.RecordFailure("rule", fmt.Errorf("switch failed")) {
+		t.Error("failure count was not reset by the success")
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConditionResult 单个匹配条件的评估结果
+type ConditionResult struct {
+	Field   string `json:"field"`             // 条件字段，例如 app、window、enabled
+	Mode    string `json:"mode,omitempty"`    // 匹配方式
+	Pattern string `json:"pattern,omitempty"` // 规则中的模式
+	Value   string `json:"value"`             // 窗口的实际值
+	Passed  bool   `json:"passed"`            // 是否通过
+	Detail  string `json:"detail,omitempty"`  // 说明
+}
+
+// CandidateExplanation 候选规则的评估过程
+type CandidateExplanation struct {
+	Index      int               `json:"index"`      // 规则在所有规则中的顺序
+	Location   string            `json:"location"`   // 规则在配置中的位置，例如 rules[0]、profiles.work.rules[1]、rules.d/team.json:rules[0]、policy:rules[0]
+	Profile    string            `json:"profile,omitempty"` // 规则所属的配置方案，共享规则为空
+	Rule       Rule              `json:"rule"`       // 规则内容
+	Conditions []ConditionResult `json:"conditions"` // 各条件的评估结果
+	Matched    bool              `json:"matched"`    // 是否匹配
+	Score      int               `json:"score"`      // 匹配得分
+	Selected   bool              `json:"selected"`   // 是否为最终选中的规则
+}
+
+// FallbackDecision 没有规则匹配时的兜底行为
+type FallbackDecision struct {
+	Mode  string    `json:"mode"`            // none / default / restore
+	Input InputList `json:"input,omitempty"` // 将要切换到的输入法
+}
+
+// MatchExplanation 一次匹配的完整解释
+type MatchExplanation struct {
+	Window     WindowInfo             `json:"window"`             // 被匹配的窗口
+	Profile    string                 `json:"profile,omitempty"`  // 当前启用的配置方案
+	Candidates []CandidateExplanation `json:"candidates"`         // 所有候选规则
+	Decision   *Rule                  `json:"decision,omitempty"` // 最终选中的规则
+	Fallback   *FallbackDecision      `json:"fallback,omitempty"` // 没有匹配时的兜底行为
+	Ignored    bool                   `json:"ignored"`            // 应用是否在全局忽略列表中
+	Reason     string                 `json:"reason"`             // 决策说明
+}
+
+// Explain 解释窗口的匹配过程，返回每条规则的评估结果和最终决策
+func (ms *MatcherService) Explain(window *WindowInfo) *MatchExplanation {
+	if window == nil {
+		return nil
+	}
+
+	explanation := &MatchExplanation{Window: *window}
+	snapshot := ms.current()
+	if snapshot == nil {
+		explanation.Reason = "配置未加载"
+		return explanation
+	}
+	config := snapshot.config
+
+	// 评估所有启用的规则，未启用的配置方案中的规则不参与选择
+	now := ms.currentTime()
+	profile, _ := snapshot.activeProfile(ms.hostname, now)
+	explanation.Profile = profile
+	evaluations := make(map[int]ruleEvaluation)
+	var best *ruleEvaluation
+	compiled := snapshot.compiled.rules
+	for i := range compiled {
+		eval := compiled[i].evaluate(window, now)
+		if !compiled[i].inProfile(profile) {
+			eval.matched = false
+			eval.score = 0
+			eval.conditions = append([]ConditionResult{{
+				Field:   "profile",
+				Pattern: compiled[i].ref.profile,
+				Value:   profile,
+				Passed:  false,
+				Detail:  "规则所属的配置方案未启用",
+			}}, eval.conditions...)
+		}
+		evaluations[compiled[i].index] = eval
+		if eval.matched && (best == nil || eval.better(*best)) {
+			e := eval
+			best = &e
+		}
+	}
+
+	// 按配置中的顺序输出所有规则，包括已禁用的规则
+	for i, ref := range config.ruleRefs() {
+		candidate := CandidateExplanation{
+			Index:    i,
+			Location: ref.location,
+			Profile:  ref.profile,
+			Rule:     config.rule(ref).Clone(),
+		}
+		eval, enabled := evaluations[i]
+		if !enabled {
+			candidate.Conditions = []ConditionResult{{
+				Field:  "enabled",
+				Value:  "false",
+				Passed: false,
+				Detail: "规则已禁用",
+			}}
+		} else {
+			candidate.Conditions = eval.conditions
+			candidate.Matched = eval.matched
+			candidate.Score = eval.score
+			candidate.Selected = best != nil && best.cr.index == i
+		}
+		explanation.Candidates = append(explanation.Candidates, candidate)
+	}
+
+	if snapshot.isIgnored(window) {
+		explanation.Ignored = true
+		explanation.Reason = "应用在全局忽略列表中，不触发切换"
+		return explanation
+	}
+
+	if best == nil {
+		fallback := config.General.Fallback
+		explanation.Fallback = &FallbackDecision{Mode: fallback.Mode}
+		switch fallback.Mode {
+		case FallbackDefault:
+			explanation.Fallback.Input = InputList(cloneStrings(fallback.Input))
+			explanation.Reason = fmt.Sprintf("没有匹配的规则，切换到默认输入法 %s", fallback.Input)
+		case FallbackRestore:
+			explanation.Reason = "没有匹配的规则，恢复该应用上次使用的输入法"
+		default:
+			explanation.Reason = "没有匹配的规则，保持当前输入法"
+		}
+		return explanation
+	}
+
+	rule := best.cr.rule.Clone()
+	explanation.Decision = &rule
+	explanation.Reason = describeDecision(best, evaluations)
+	return explanation
+}
+
+// describeDecision 生成决策说明，包括得分构成以及与其他匹配规则的比较
+func describeDecision(best *ruleEvaluation, evaluations map[int]ruleEvaluation) string {
+	parts := []string{fmt.Sprintf("应用名称按 %s 匹配 %q", best.appMode, best.appPattern)}
+	if best.cr.window != nil {
+		parts = append(parts, "窗口条件命中")
+	}
+	reason := fmt.Sprintf("选中规则 %s（%s），得分 %d（%s）", best.cr.rule.ID, best.cr.ref.location, best.score, strings.Join(parts, "，"))
+
+	var tied int
+	for _, eval := range evaluations {
+		if eval.matched && eval.cr.index != best.cr.index && eval.score == best.score {
+			tied++
+		}
+	}
+	if tied > 0 {
+		reason += fmt.Sprintf("；另有 %d 条规则得分相同，按优先级和配置顺序选择", tied)
+	}
+	return reason
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplainCandidates(t *testing.T) {
+	disabled := testRule("disabled", "Terminal", MatchExact, 1)
+	disabled.Enabled = false
+	ms := newTestMatcher(t, []Rule{
+		testRule("prefix", "Term", MatchPrefix, 1),
+		testRule("exact", "Terminal", MatchExact, 5),
+		disabled,
+		testRule("safari", "Safari", MatchExact, 1),
+	})
+
+	explanation := ms.Explain(&WindowInfo{AppName: "Terminal"})
+	if explanation.Decision == nil || explanation.Decision.ID != "exact" {
+		t.Fatalf("Decision = %+v, want rule exact", explanation.Decision)
+	}
+	if !strings.Contains(explanation.Reason, "exact") || !strings.Contains(explanation.Reason, "rules[1]") {
+		t.Errorf("Reason %q does not name the selected rule and its location", explanation.Reason)
+	}
+
+	tests := []struct {
+		id       string
+		location string
+		matched  bool
+		score    int
+		selected bool
+	}{
+		{"prefix", "rules[0]", true, 400, false},
+		{"exact", "rules[1]", true, 500, true},
+		{"disabled", "rules[2]", false, 0, false},
+		{"safari", "rules[3]", false, 0, false},
+	}
+	if len(explanation.Candidates) != len(tests) {
+		t.Fatalf("got %d candidates, want %d", len(explanation.Candidates), len(tests))
+	}
+	for i, tt := range tests {
+		c := explanation.Candidates[i]
+		if c.Rule.ID != tt.id || c.Location != tt.location || c.Matched != tt.matched || c.Score != tt.score || c.Selected != tt.selected {
+			t.Errorf("candidate %d = {id %s, location %s, matched %v, score %d, selected %v}, want %+v",
+				i, c.Rule.ID, c.Location, c.Matched, c.Score, c.Selected, tt)
+		}
+		if len(c.Conditions) == 0 {
+			t.Errorf("candidate %s has no conditions", c.Rule.ID)
+		}
+	}
+
+	// 已禁用的规则说明原因，未匹配的规则标出失败的条件
+	if c := explanation.Candidates[2].Conditions[0]; c.Field != "enabled" || c.Passed {
+		t.Errorf("disabled rule condition = %+v", c)
+	}
+	failed := false
+	for _, c := range explanation.Candidates[3].Conditions {
+		if c.Field == "app" && !c.Passed && c.Value == "Terminal" {
+			failed = true
+		}
+	}
+	if !failed {
+		t.Errorf("unmatched rule has no failed app condition: %+v", explanation.Candidates[3].Conditions)
+	}
+}
+
+func TestExplainTie(t *testing.T) {
+	ms := newTestMatcher(t, []Rule{
+		testRule("second", "Terminal", MatchExact, 2),
+		testRule("first", "Terminal", MatchExact, 1),
+	})
+
+	explanation := ms.Explain(&WindowInfo{AppName: "Terminal"})
+	if explanation.Decision == nil || explanation.Decision.ID != "first" {
+		t.Fatalf("Decision = %+v, want rule first", explanation.Decision)
+	}
+	if !strings.Contains(explanation.Reason, "另有 1 条规则得分相同") {
+		t.Errorf("Reason %q does not mention the tie", explanation.Reason)
+	}
+}
+
+func TestExplainAgreesWithMatchWindow(t *testing.T) {
+	ms := newTestMatcher(t, []Rule{
+		testRule("contains", "Chrome", MatchContains, 1),
+		testRule("glob", "*Chrome", MatchGlob, 1),
+		testRule("fuzzy", "Chrome", "", 1),
+		testRule("prefix", "Google", MatchPrefix, 2),
+	})
+
+	for _, app := range []string{"Google Chrome", "Chrome Canary", "Chromium", "Safari"} {
+		window := &WindowInfo{AppName: app}
+		want := ""
+		if rule := ms.MatchWindow(window); rule != nil {
+			want = rule.ID
+		}
+		got := ""
+		if explanation := ms.Explain(window); explanation.Decision != nil {
+			got = explanation.Decision.ID
+		}
+		if got != want {
+			t.Errorf("%s: Explain selected %q, MatchWindow selected %q", app, got, want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// 配置文件格式
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// configFileNames 查找配置文件时依次尝试的文件名
+var configFileNames = []string{"config.json", "config.jsonc", "config.yaml", "config.yml", "config.toml"}
+
+// FindConfigFile 返回目录中已存在的配置文件，按 json、yaml、toml 的顺序查找
+// 都不存在时返回 config.json，首次启动时会在该位置创建默认配置
+func FindConfigFile(dir string) string {
+	for _, name := range configFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(dir, configFileNames[0])
+}
+
+// configFormat 根据扩展名判断配置文件格式，.jsonc 和无法识别的扩展名按 JSON（允许注释）处理
+func configFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatJSON
+	}
+}
+
+// decodeConfigData 将配置文件内容转换为标准 JSON，之后的升级、解析和校验都基于 JSON 进行
+func decodeConfigData(data []byte, format string) ([]byte, error) {
+	var value interface{}
+	switch format {
+	case FormatYAML:
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML config: %v", err)
+		}
+	case FormatTOML:
+		var table map[string]interface{}
+		if _, err := toml.Decode(string(data), &table); err != nil {
+			return nil, fmt.Errorf("failed to parse TOML config: %v", err)
+		}
+		value = table
+	default:
+		standard, err := standardizeJSONC(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse config: %v", err)
+		}
+		return standard, nil
+	}
+
+	// 空文件视为空配置
+	if value == nil {
+		value = map[string]interface{}{}
+	}
+	converted, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s config: %v", format, err)
+	}
+	return converted, nil
+}
+
+// encodeConfigData 将 JSON 格式的配置转换为指定格式
+// JSON 格式时合并到原文件内容 original 中，保留原文件的注释和排版
+func encodeConfigData(data, original []byte, format string) ([]byte, error) {
+	switch format {
+	case FormatYAML:
+		// 通过节点转换以保持字段顺序，并改为块格式输出
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return nil, fmt.Errorf("failed to convert config to YAML: %v", err)
+		}
+		resetYAMLStyle(&node)
+
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&node); err != nil {
+			return nil, fmt.Errorf("failed to convert config to YAML: %v", err)
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, fmt.Errorf("failed to convert config to YAML: %v", err)
+		}
+		return buf.Bytes(), nil
+	case FormatTOML:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("failed to convert config to TOML: %v", err)
+		}
+
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(tomlValue(value)); err != nil {
+			return nil, fmt.Errorf("failed to convert config to TOML: %v", err)
+		}
+		return buf.Bytes(), nil
+	default:
+		return patchJSONC(original, data)
+	}
+}
+
+// resetYAMLStyle 清除从 JSON 解析得到的流式和引号样式，需要引号的字符串在输出时会自动加上
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
+
+// tomlValue 将 JSON 值转换为 TOML 可以表示的值：去掉 null，整数保持为整数
+func tomlValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		table := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if item != nil {
+				table[key] = tomlValue(item)
+			}
+		}
+		return table
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			if item != nil {
+				list = append(list, tomlValue(item))
+			}
+		}
+		return list
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	default:
+		return v
+	}
+}
+
+// ValidateConfigFile 按扩展名识别格式后校验配置文件内容
+// YAML 和 TOML 先转换为 JSON 再校验，此时问题只报告配置项位置，不报告行号
+func ValidateConfigFile(path string, data []byte, availableInputs []string) *ValidationResult {
+	format := configFormat(path)
+	if format == FormatJSON {
+		return ValidateConfigData(data, availableInputs)
+	}
+
+	converted, err := decodeConfigData(data, format)
+	if err != nil {
+		return &ValidationResult{Issues: []ValidationIssue{{Severity: SeverityError, Message: err.Error()}}}
+	}
+
+	result := ValidateConfigData(converted, availableInputs)
+	for i := range result.Issues {
+		result.Issues[i].Line = 0
+		result.Issues[i].Column = 0
+	}
+	return result
+}
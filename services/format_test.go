@@ -0,0 +1,222 @@
+package services
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigFormatRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+	}{
+		{
+			name:   "config.yaml",
+			format: FormatYAML,
+			data: `version: 1
+general:
+  checkInterval: 500
+  fallback:
+    mode: default
+    input: com.apple.keylayout.ABC
+inputs:
+  cn: [com.tencent.inputmethod.wetype.pinyin, com.apple.inputmethod.SCIM.ITABC]
+rules:
+  - id: terminal
+    app: Terminal
+    input: com.apple.keylayout.ABC
+    enabled: true
+    priority: 1
+  - id: wechat
+    app: "WeChat: 微信"
+    input: cn
+    enabled: true
+    priority: 2
+    schedule:
+      times: ["09:00-18:00"]
+`,
+		},
+		{
+			name:   "config.toml",
+			format: FormatTOML,
+			data: `version = 1
+
+[general]
+checkInterval = 500
+
+[general.fallback]
+mode = "default"
+input = "com.apple.keylayout.ABC"
+
+[inputs]
+cn = ["com.tencent.inputmethod.wetype.pinyin", "com.apple.inputmethod.SCIM.ITABC"]
+
+[[rules]]
+id = "terminal"
+app = "Terminal"
+input = "com.apple.keylayout.ABC"
+enabled = true
+priority = 1
+
+[[rules]]
+id = "wechat"
+app = "WeChat: 微信"
+input = "cn"
+enabled = true
+priority = 2
+
+[rules.schedule]
+times = ["09:00-18:00"]
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.name)
+			if err := ioutil.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			ms := NewMatcherService(path)
+			ms.SetSystemConfigDir("")
+			if err := ms.LoadConfig(); err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			before := ms.GetConfig()
+
+			added, err := ms.AddRule(Rule{AppName: "Safari", Input: InputList{"cn"}, Enabled: true})
+			if err != nil {
+				t.Fatalf("AddRule: %v", err)
+			}
+
+			// 保存后仍是原来的格式，不是 JSON
+			written, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if json.Valid(written) {
+				t.Errorf("saved %s is JSON:\n%s", tt.name, written)
+			}
+			if _, err := decodeConfigData(written, tt.format); err != nil {
+				t.Fatalf("saved file cannot be parsed: %v\n%s", err, written)
+			}
+
+			// 重新加载后内容不变，新规则被保存
+			reloaded := NewMatcherService(path)
+			reloaded.SetSystemConfigDir("")
+			if err := reloaded.LoadConfig(); err != nil {
+				t.Fatalf("LoadConfig after save: %v\n%s", err, written)
+			}
+			after := reloaded.GetConfig()
+			if len(after.Rules) != len(before.Rules)+1 || after.Rules[len(after.Rules)-1].ID != added.ID {
+				t.Fatalf("reloaded rules = %+v, want the original rules and %s", after.Rules, added.ID)
+			}
+			if !reflect.DeepEqual(after.Rules[:len(before.Rules)], before.Rules) {
+				t.Errorf("rules changed after saving:\nbefore %+v\nafter  %+v", before.Rules, after.Rules)
+			}
+			if !reflect.DeepEqual(after.Inputs, before.Inputs) || !reflect.DeepEqual(after.General.Fallback, before.General.Fallback) {
+				t.Errorf("inputs or fallback changed after saving:\n%s", written)
+			}
+			if after.General.CheckInterval != 500 {
+				t.Errorf("checkInterval = %d after saving, want 500", after.General.CheckInterval)
+			}
+			if rule := reloaded.MatchWindow(&WindowInfo{AppName: "WeChat: 微信"}); rule == nil || rule.ID != "wechat" {
+				t.Errorf("MatchWindow(WeChat) after saving = %v", rule)
+			}
+		})
+	}
+}
+
+func TestDecodeConfigDataErrors(t *testing.T) {
+	tests := []struct {
+		format string
+		data   string
+		want   string
+	}{
+		{FormatYAML, "rules: [\n", "failed to parse YAML config"},
+		{FormatTOML, "rules = [\n", "failed to parse TOML config"},
+		{FormatJSON, `{"rules": [`, "failed to parse config"},
+	}
+	for _, tt := range tests {
+		if _, err := decodeConfigData([]byte(tt.data), tt.format); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("decodeConfigData(%s) error = %v, want %q", tt.format, err, tt.want)
+		}
+	}
+
+	// 空的 YAML 和 TOML 文件视为空配置
+	for _, format := range []string{FormatYAML, FormatTOML} {
+		if data, err := decodeConfigData(nil, format); err != nil || string(data) != "{}" {
+			t.Errorf("decodeConfigData(empty %s) = %s, %v, want {}", format, data, err)
+		}
+	}
+}
+
+func TestJSONCSaveKeepsUntouchedRules(t *testing.T) {
+	original := `{
+  // 配置版本
+  "version": 1,
+  "general": {
+    "checkInterval": 500, // 毫秒
+  },
+  "rules": [
+    /* 终端使用英文 */
+    {"id": "terminal", "app": "Terminal", "input": "com.apple.keylayout.ABC", "enabled": true},
+    {
+      "id": "wechat",
+      "app": "WeChat",
+      "input": [
+        "com.tencent.inputmethod.wetype.pinyin", // 首选
+        "com.apple.inputmethod.SCIM.ITABC",
+      ],
+      "enabled": true,
+    },
+    {"id": "chrome", "app": "Chrome", "window": "GitHub", "input": "com.apple.keylayout.ABC", "enabled": true, "priority": 3},
+  ],
+}
+`
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ms := NewMatcherService(path)
+	ms.SetSystemConfigDir("")
+	if err := ms.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	// 修改一条规则并添加一条规则，其余规则的内容保持原样
+	chrome := ms.GetConfig().Rules[2]
+	chrome.Enabled = false
+	if err := ms.UpdateRule("chrome", chrome); err != nil {
+		t.Fatalf("UpdateRule: %v", err)
+	}
+	added, err := ms.AddRule(Rule{AppName: "Safari", Input: InputList{"com.apple.keylayout.ABC"}, Enabled: true})
+	if err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+
+	written, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := string(written)
+	// 保存时会加上 lastModified，只比较规则部分
+	untouched := original[strings.Index(original, `  "rules": [`):strings.Index(original, `    {"id": "chrome"`)]
+	if !strings.Contains(saved, untouched) {
+		t.Errorf("untouched rules changed:\n%s", saved)
+	}
+	if !strings.Contains(saved, `{"id": "chrome", "app": "Chrome", "window": "GitHub", "input": "com.apple.keylayout.ABC", "enabled": false, "priority": 3}`) {
+		t.Errorf("updated rule was not patched in place:\n%s", saved)
+	}
+	if !strings.Contains(saved, added.ID) {
+		t.Errorf("added rule %s is missing:\n%s", added.ID, saved)
+	}
+	if strings.Count(saved, `"window"`) != 1 || strings.Count(saved, `"priority"`) != 2 {
+		t.Errorf("empty window or priority fields were added:\n%s", saved)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"os/exec"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 )
 
 // InputMethod 输入法信息
@@ -13,27 +15,43 @@ type InputMethod struct {
 	Name string `json:"name"`
 }
 
-// InputService 输入法管理服务
-type InputService struct {
-	currentInput string
+// InputBackend 输入法切换后端
+type InputBackend interface {
+	Name() string                           // 后端名称
+	GetCurrentInput() (*InputMethod, error) // 获取当前输入法
+	SwitchInput(inputID string) error       // 切换输入法
+	HealthCheck() error                     // 健康检查
 }
 
-// NewInputService 创建新的输入法管理服务
-func NewInputService() *InputService {
-	return &InputService{}
+// BackendHealth 后端健康状态
+type BackendHealth struct {
+	Name                string    `json:"name"`
+	Healthy             bool      `json:"healthy"`
+	Active              bool      `json:"active"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LastError           string    `json:"lastError,omitempty"`
+	LastCheck           time.Time `json:"lastCheck"`
+	LastSuccess         time.Time `json:"lastSuccess"`
 }
 
-// GetCurrentInput 获取当前输入法
-func (is *InputService) GetCurrentInput() (*InputMethod, error) {
-	if runtime.GOOS != "darwin" {
-		return nil, fmt.Errorf("unsupported platform: %s", runtime.GOOS)
-	}
-	return is.getCurrentInputMac()
+// ImSelectBackend 基于 im-select 命令行工具的后端
+type ImSelectBackend struct {
+	path string
+}
+
+// NewImSelectBackend 创建 im-select 后端，path 为可执行文件路径
+func NewImSelectBackend(path string) *ImSelectBackend {
+	return &ImSelectBackend{path: path}
 }
 
-// getCurrentInputMac macOS下获取当前输入法
-func (is *InputService) getCurrentInputMac() (*InputMethod, error) {
-	cmd := exec.Command("/opt/homebrew/bin/im-select")
+// Name 后端名称
+func (b *ImSelectBackend) Name() string {
+	return "im-select:" + b.path
+}
+
+// GetCurrentInput 使用 im-select 获取当前输入法
+func (b *ImSelectBackend) GetCurrentInput() (*InputMethod, error) {
+	cmd := exec.Command(b.path)
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get current input: %v", err)
@@ -50,47 +68,291 @@ func (is *InputService) getCurrentInputMac() (*InputMethod, error) {
 	}, nil
 }
 
+// SwitchInput 使用 im-select 切换输入法
+func (b *ImSelectBackend) SwitchInput(inputID string) error {
+	cmd := exec.Command(b.path, inputID)
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("failed to switch input: %v", err)
+	}
+
+	return nil
+}
+
+// HealthCheck 检查 im-select 是否存在并能正常返回当前输入法
+func (b *ImSelectBackend) HealthCheck() error {
+	if _, err := exec.LookPath(b.path); err != nil {
+		return fmt.Errorf("im-select not found: %v", err)
+	}
+	_, err := b.GetCurrentInput()
+	return err
+}
+
+// InputService 输入法管理服务
+type InputService struct {
+	backends         []InputBackend
+	health           []*BackendHealth
+	failureThreshold int // 连续失败多少次后切换到下一个后端
+	healthMutex      sync.RWMutex
+	stopChan         chan bool
+	onBackendChange  func(from, to string)
+	remembered       map[string]string // 应用名称 -> 离开该应用时使用的输入法
+	rememberMutex    sync.RWMutex
+}
+
+// NewInputService 创建新的输入法管理服务
+// backends 为按优先顺序排列的后端链，为空时使用默认的 im-select 路径
+func NewInputService(backends ...InputBackend) *InputService {
+	if len(backends) == 0 {
+		backends = []InputBackend{
+			NewImSelectBackend("/opt/homebrew/bin/im-select"),
+			NewImSelectBackend("/usr/local/bin/im-select"),
+			NewImSelectBackend("im-select"),
+		}
+	}
+
+	health := make([]*BackendHealth, len(backends))
+	for i, backend := range backends {
+		health[i] = &BackendHealth{
+			Name:    backend.Name(),
+			Healthy: true,
+		}
+	}
+
+	return &InputService{
+		backends:         backends,
+		health:           health,
+		failureThreshold: 3,
+		stopChan:         make(chan bool),
+		remembered:       make(map[string]string),
+	}
+}
+
+// RememberInput 记录应用当前使用的输入法
+func (is *InputService) RememberInput(appName, inputID string) {
+	is.rememberMutex.Lock()
+	defer is.rememberMutex.Unlock()
+	is.remembered[appName] = inputID
+}
+
+// RememberedInput 获取应用上次使用的输入法
+func (is *InputService) RememberedInput(appName string) (string, bool) {
+	is.rememberMutex.RLock()
+	defer is.rememberMutex.RUnlock()
+	inputID, exists := is.remembered[appName]
+	return inputID, exists
+}
+
+// SetBackendChangeCallback 设置活动后端变化回调
+func (is *InputService) SetBackendChangeCallback(callback func(from, to string)) {
+	is.healthMutex.Lock()
+	defer is.healthMutex.Unlock()
+	is.onBackendChange = callback
+}
+
+// GetBackendHealth 获取所有后端的健康状态
+func (is *InputService) GetBackendHealth() []BackendHealth {
+	is.healthMutex.RLock()
+	defer is.healthMutex.RUnlock()
+
+	active := is.activeIndexLocked()
+	result := make([]BackendHealth, len(is.health))
+	for i, health := range is.health {
+		result[i] = *health
+		result[i].Active = i == active
+	}
+	return result
+}
+
+// activeIndexLocked 返回当前活动后端的索引（第一个健康的后端），调用方需持有锁
+func (is *InputService) activeIndexLocked() int {
+	for i, health := range is.health {
+		if health.Healthy {
+			return i
+		}
+	}
+	return -1
+}
+
+// backendOrder 返回本次操作尝试后端的顺序：健康的后端在前，不健康的在后
+func (is *InputService) backendOrder() []int {
+	is.healthMutex.RLock()
+	defer is.healthMutex.RUnlock()
+
+	var healthy, unhealthy []int
+	for i, health := range is.health {
+		if health.Healthy {
+			healthy = append(healthy, i)
+		} else {
+			unhealthy = append(unhealthy, i)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// recordResult 记录后端调用结果，并在活动后端变化时触发回调
+// fromHealthCheck 为 true 时一次失败即判定为不健康
+func (is *InputService) recordResult(index int, err error, fromHealthCheck bool) {
+	is.healthMutex.Lock()
+	before := is.activeIndexLocked()
+
+	health := is.health[index]
+	now := time.Now()
+	if fromHealthCheck {
+		health.LastCheck = now
+	}
+	if err == nil {
+		health.Healthy = true
+		health.ConsecutiveFailures = 0
+		health.LastError = ""
+		health.LastSuccess = now
+	} else {
+		health.ConsecutiveFailures++
+		health.LastError = err.Error()
+		if fromHealthCheck || health.ConsecutiveFailures >= is.failureThreshold {
+			health.Healthy = false
+		}
+	}
+
+	after := is.activeIndexLocked()
+	callback := is.onBackendChange
+	is.healthMutex.Unlock()
+
+	if before != after && callback != nil {
+		callback(is.backendName(before), is.backendName(after))
+	}
+}
+
+// backendName 返回后端名称，索引无效时返回 "none"
+func (is *InputService) backendName(index int) string {
+	if index < 0 || index >= len(is.backends) {
+		return "none"
+	}
+	return is.backends[index].Name()
+}
+
+// RunHealthChecks 对所有后端执行一次健康检查
+func (is *InputService) RunHealthChecks() {
+	for i, backend := range is.backends {
+		is.recordResult(i, backend.HealthCheck(), true)
+	}
+}
+
+// StartHealthChecks 定期执行健康检查，不健康的首选后端恢复后会自动切回
+func (is *InputService) StartHealthChecks(interval time.Duration) {
+	is.RunHealthChecks()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			is.RunHealthChecks()
+		case <-is.stopChan:
+			return
+		}
+	}
+}
+
+// StopHealthChecks 停止定期健康检查
+func (is *InputService) StopHealthChecks() {
+	close(is.stopChan)
+}
+
+// GetCurrentInput 获取当前输入法
+func (is *InputService) GetCurrentInput() (*InputMethod, error) {
+	if runtime.GOOS != "darwin" {
+		return nil, fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+	}
+
+	var lastErr error
+	for _, index := range is.backendOrder() {
+		input, err := is.backends[index].GetCurrentInput()
+		is.recordResult(index, err, false)
+		if err == nil {
+			return input, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
 
 // SwitchInput 切换到指定输入法
 func (is *InputService) SwitchInput(inputID string) error {
+	_, err := is.SwitchInputWithBackend(inputID)
+	return err
+}
+
+// SwitchInputWithBackend 切换到指定输入法，并返回最后尝试的后端名称
+// 依次尝试后端链，直到某个后端切换成功
+func (is *InputService) SwitchInputWithBackend(inputID string) (string, error) {
 	if runtime.GOOS != "darwin" {
-		return fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+		return "", fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+	}
+
+	var lastErr error
+	backend := ""
+	for _, index := range is.backendOrder() {
+		backend = is.backends[index].Name()
+		err := is.backends[index].SwitchInput(inputID)
+		is.recordResult(index, err, false)
+		if err == nil {
+			return backend, nil
+		}
+		lastErr = fmt.Errorf("%s: %v", backend, err)
 	}
-	return is.switchInputMac(inputID)
+	return backend, lastErr
 }
 
-// switchInputMac macOS下切换输入法
-func (is *InputService) switchInputMac(inputID string) error {
-	cmd := exec.Command("/opt/homebrew/bin/im-select", inputID)
-	err := cmd.Run()
-	if err != nil {
-		return fmt.Errorf("failed to switch input: %v", err)
+// SwitchFirstInput 按顺序尝试候选输入法，切换到第一个能切换成功的输入法
+// 返回选中的输入法ID、最后尝试的后端名称以及选择原因
+// im-select 切换到未安装的输入法时不会报错，因此还有后续候选项时会读取当前输入法确认切换结果
+func (is *InputService) SwitchFirstInput(candidates []string) (string, string, string, error) {
+	if len(candidates) == 0 {
+		return "", "", "", fmt.Errorf("no input candidates")
 	}
 
-	return nil
+	var skipped []string
+	backend := ""
+	for i, candidate := range candidates {
+		var err error
+		backend, err = is.SwitchInputWithBackend(candidate)
+		if err == nil && i < len(candidates)-1 {
+			if current, currentErr := is.GetCurrentInput(); currentErr == nil && current.ID != candidate {
+				err = fmt.Errorf("not installed (current input is %s)", current.ID)
+			}
+		}
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", candidate, err))
+			continue
+		}
+		if i == 0 {
+			return candidate, backend, "首选输入法可用", nil
+		}
+		return candidate, backend, fmt.Sprintf("第%d个候选项可用，跳过无法切换的: %s", i+1, strings.Join(skipped, "; ")), nil
+	}
+
+	return "", backend, "", fmt.Errorf("none of the inputs are available: %s", strings.Join(skipped, "; "))
 }
 
+// InstalledInputIDs 返回系统中已启用的输入法ID，用于检查配置中的输入法是否可用
+func (is *InputService) InstalledInputIDs() ([]string, error) {
+	inputs, err := is.GetAvailableInputs()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		ids = append(ids, input.ID)
+	}
+	return ids, nil
+}
 
-// GetAvailableInputs 获取可用的输入法列表
+// GetAvailableInputs 获取系统中已启用的输入法列表
 func (is *InputService) GetAvailableInputs() ([]*InputMethod, error) {
 	if runtime.GOOS != "darwin" {
 		return nil, fmt.Errorf("unsupported platform: %s", runtime.GOOS)
 	}
 	return is.getAvailableInputsMac()
 }
-
-// getAvailableInputsMac macOS下获取可用输入法
-func (is *InputService) getAvailableInputsMac() ([]*InputMethod, error) {
-	inputs := []*InputMethod{}
-	inputs = append(inputs, &InputMethod{
-		ID:   "com.apple.keylayout.ABC",
-		Name: "ABC",
-	})
-	inputs = append(inputs, &InputMethod{
-		ID:   "com.tencent.inputmethod.wetype.pinyin",
-		Name: "拼音",
-	})
-
-	return inputs, nil
-}
-
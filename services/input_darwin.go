@@ -0,0 +1,83 @@
+//go:build darwin
+
+package services
+
+/*
+#cgo LDFLAGS: -framework Carbon -framework CoreFoundation
+#include <Carbon/Carbon.h>
+#include <stdlib.h>
+
+// appendProperty 把输入源的字符串属性追加到 result 中
+static void appendProperty(CFMutableStringRef result, TISInputSourceRef source, CFStringRef key) {
+	CFStringRef value = (CFStringRef)TISGetInputSourceProperty(source, key);
+	if (value != NULL) {
+		CFStringAppend(result, value);
+	}
+}
+
+// enabledInputSources 返回已启用并且可以切换到的键盘输入源，每行为“ID\t名称”，调用方负责释放
+static char *enabledInputSources(void) {
+	CFArrayRef sources = TISCreateInputSourceList(NULL, false);
+	if (sources == NULL) {
+		return NULL;
+	}
+
+	CFMutableStringRef result = CFStringCreateMutable(NULL, 0);
+	CFIndex count = CFArrayGetCount(sources);
+	for (CFIndex i = 0; i < count; i++) {
+		TISInputSourceRef source = (TISInputSourceRef)CFArrayGetValueAtIndex(sources, i);
+		CFStringRef category = (CFStringRef)TISGetInputSourceProperty(source, kTISPropertyInputSourceCategory);
+		if (category == NULL || !CFEqual(category, kTISCategoryKeyboardInputSource)) {
+			continue;
+		}
+		CFBooleanRef selectable = (CFBooleanRef)TISGetInputSourceProperty(source, kTISPropertyInputSourceIsSelectCapable);
+		if (selectable == NULL || !CFBooleanGetValue(selectable)) {
+			continue;
+		}
+		appendProperty(result, source, kTISPropertyInputSourceID);
+		CFStringAppend(result, CFSTR("\t"));
+		appendProperty(result, source, kTISPropertyLocalizedName);
+		CFStringAppend(result, CFSTR("\n"));
+	}
+	CFRelease(sources);
+
+	CFIndex size = CFStringGetMaximumSizeForEncoding(CFStringGetLength(result), kCFStringEncodingUTF8) + 1;
+	char *buffer = malloc(size);
+	if (buffer != NULL && !CFStringGetCString(result, buffer, size, kCFStringEncodingUTF8)) {
+		free(buffer);
+		buffer = NULL;
+	}
+	CFRelease(result);
+	return buffer;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// getAvailableInputsMac macOS下通过 Text Input Sources 获取已启用的键盘输入源
+// 输入源ID与 im-select 使用的ID相同
+func (is *InputService) getAvailableInputsMac() ([]*InputMethod, error) {
+	list := C.enabledInputSources()
+	if list == nil {
+		return nil, fmt.Errorf("failed to list input sources")
+	}
+	defer C.free(unsafe.Pointer(list))
+
+	inputs := []*InputMethod{}
+	for _, line := range strings.Split(C.GoString(list), "\n") {
+		id, name, _ := strings.Cut(line, "\t")
+		if id == "" {
+			continue
+		}
+		if name == "" {
+			name = id
+		}
+		inputs = append(inputs, &InputMethod{ID: id, Name: name})
+	}
+	return inputs, nil
+}
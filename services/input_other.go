@@ -0,0 +1,13 @@
+//go:build !darwin
+
+package services
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// getAvailableInputsMac 只在 macOS 下可以获取输入源
+func (is *InputService) getAvailableInputsMac() ([]*InputMethod, error) {
+	return nil, fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+}
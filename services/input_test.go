@@ -0,0 +1,115 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+)
+
+// fakeBackend 测试用的后端，err 不为空时所有操作都失败
+type fakeBackend struct {
+	name    string
+	err     error
+	current string
+}
+
+func (b *fakeBackend) Name() string { return b.name }
+
+func (b *fakeBackend) GetCurrentInput() (*InputMethod, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return &InputMethod{ID: b.current, Name: b.current}, nil
+}
+
+func (b *fakeBackend) SwitchInput(inputID string) error {
+	if b.err != nil {
+		return b.err
+	}
+	b.current = inputID
+	return nil
+}
+
+func (b *fakeBackend) HealthCheck() error { return b.err }
+
+// activeBackend 返回当前活动后端的名称
+func activeBackend(is *InputService) string {
+	for _, health := range is.GetBackendHealth() {
+		if health.Active {
+			return health.Name
+		}
+	}
+	return "none"
+}
+
+func TestInputServiceBackendFailover(t *testing.T) {
+	primary := &fakeBackend{name: "primary"}
+	secondary := &fakeBackend{name: "secondary"}
+	is := NewInputService(primary, secondary)
+
+	var changes []string
+	is.SetBackendChangeCallback(func(from, to string) {
+		changes = append(changes, from+" -> "+to)
+	})
+
+	if got := activeBackend(is); got != "primary" {
+		t.Fatalf("active backend = %s, want primary", got)
+	}
+
+	// 连续失败达到阈值前仍使用首选后端
+	primary.err = fmt.Errorf("broken")
+	for i := 1; i < is.failureThreshold; i++ {
+		is.recordResult(0, primary.err, false)
+	}
+	if got := activeBackend(is); got != "primary" {
+		t.Fatalf("active backend after %d failures = %s, want primary", is.failureThreshold-1, got)
+	}
+
+	is.recordResult(0, primary.err, false)
+	if got := activeBackend(is); got != "secondary" {
+		t.Fatalf("active backend after %d failures = %s, want secondary", is.failureThreshold, got)
+	}
+	if order := is.backendOrder(); len(order) != 2 || order[0] != 1 || order[1] != 0 {
+		t.Errorf("backendOrder() = %v, want the healthy secondary first", order)
+	}
+
+	// 首选后端恢复后，健康检查会切回首选后端
+	primary.err = nil
+	is.RunHealthChecks()
+	if got := activeBackend(is); got != "primary" {
+		t.Errorf("active backend after recovery = %s, want primary", got)
+	}
+
+	want := []string{"primary -> secondary", "secondary -> primary"}
+	if fmt.Sprint(changes) != fmt.Sprint(want) {
+		t.Errorf("backend changes = %v, want %v", changes, want)
+	}
+}
+
+func TestInputServiceHealthCheckMarksUnhealthy(t *testing.T) {
+	primary := &fakeBackend{name: "primary", err: fmt.Errorf("im-select not found")}
+	secondary := &fakeBackend{name: "secondary", err: fmt.Errorf("im-select not found")}
+	is := NewInputService(primary, secondary)
+
+	// 健康检查一次失败即判定为不健康
+	is.RunHealthChecks()
+	for _, health := range is.GetBackendHealth() {
+		if health.Healthy || health.LastError == "" || health.LastCheck.IsZero() {
+			t.Errorf("backend %s after a failed health check: %+v", health.Name, health)
+		}
+	}
+	if got := activeBackend(is); got != "none" {
+		t.Errorf("active backend = %s, want none", got)
+	}
+}
+
+func TestRememberInput(t *testing.T) {
+	is := NewInputService(&fakeBackend{name: "fake"})
+	if _, exists := is.RememberedInput("Safari"); exists {
+		t.Error("RememberedInput returned an input before any was remembered")
+	}
+	is.RememberInput("Safari", "com.apple.keylayout.ABC")
+	is.RememberInput("Safari", "com.tencent.inputmethod.wetype.pinyin")
+	if inputID, exists := is.RememberedInput("Safari"); !exists || inputID != "com.tencent.inputmethod.wetype.pinyin" {
+		t.Errorf("RememberedInput(Safari) = %q, %v, want the last remembered input", inputID, exists)
+	}
+}
@@ -0,0 +1,368 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tailscale/hujson"
+)
+
+// JSON 配置文件支持 JSONC 写法，即可以带 // 和 /* */ 注释以及尾随逗号
+// 保存配置时不重新生成整个文件，而是把新配置逐个节点合并到原文件的语法树中：
+// 只改动发生变化的节点，其余部分的注释、空白、字段顺序和尾随逗号保持原样
+
+// standardizeJSONC 去掉注释和尾随逗号得到标准 JSON，去掉的内容替换为空格，行号和列号保持不变
+// hujson 会原地修改传入的内容，这里先复制一份，调用方的 data 保持不变
+func standardizeJSONC(data []byte) ([]byte, error) {
+	standard, err := hujson.Standardize(append([]byte(nil), data...))
+	if err != nil {
+		line, column, message := jsoncSyntaxError(err)
+		return nil, fmt.Errorf("line %d, column %d: %s", line, column, message)
+	}
+	return standard, nil
+}
+
+// jsoncSyntaxError 将 hujson 的语法错误拆分为行号、列号和说明
+func jsoncSyntaxError(err error) (line, column int, message string) {
+	message = strings.TrimPrefix(err.Error(), "hujson: ")
+	if _, scanErr := fmt.Sscanf(message, "line %d, column %d:", &line, &column); scanErr == nil {
+		message = strings.TrimSpace(message[strings.Index(message, ":")+1:])
+	}
+	return line, column, message
+}
+
+// patchJSONC 把 updated（标准 JSON）合并到原文件内容 original 中，返回的内容与 updated 等价
+// 原文件为空或无法解析时直接返回 updated
+func patchJSONC(original, updated []byte) ([]byte, error) {
+	root, err := hujson.Parse(original)
+	if err != nil {
+		return updated, nil
+	}
+	target, err := hujson.Parse(updated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse updated config: %v", err)
+	}
+
+	p := &jsoncPatcher{unit: detectIndent(original)}
+	p.merge(&root, target, "")
+	return root.Pack(), nil
+}
+
+// jsoncPatcher 将新值合并到原有语法树
+type jsoncPatcher struct {
+	unit string // 原文件使用的缩进单位，新增节点按此缩进
+}
+
+// merge 将 src 合并到 dst，indent 为 dst 所在行的缩进
+// 类型相同的对象和数组逐个成员合并，值相同的字面量保持原样，其余情况用新值替换
+func (p *jsoncPatcher) merge(dst *hujson.Value, src hujson.Value, indent string) {
+	switch d := dst.Value.(type) {
+	case *hujson.Object:
+		if s, ok := src.Value.(*hujson.Object); ok {
+			p.mergeObject(d, s, indent)
+			return
+		}
+	case *hujson.Array:
+		if s, ok := src.Value.(*hujson.Array); ok {
+			p.mergeArray(d, s, indent)
+			return
+		}
+	case hujson.Literal:
+		if s, ok := src.Value.(hujson.Literal); ok && equalLiteral(d, s) {
+			return
+		}
+	}
+	dst.Value = p.format(src, indent)
+}
+
+// mergeObject 合并对象：保留原有成员的顺序，删除不再存在的成员，新成员插入到新配置中前一个成员之后
+func (p *jsoncPatcher) mergeObject(dst, src *hujson.Object, indent string) {
+	values := make([]*hujson.Value, len(dst.Members))
+	for i := range dst.Members {
+		values[i] = &dst.Members[i].Name
+	}
+	var last *hujson.Value
+	if n := len(dst.Members); n > 0 {
+		last = &dst.Members[n-1].Value
+	}
+	layout := p.captureLayout(values, last, indent)
+
+	updated := make(map[string]hujson.Value, len(src.Members))
+	for _, m := range src.Members {
+		updated[memberName(m)] = m.Value
+	}
+
+	members := make([]hujson.ObjectMember, 0, len(src.Members))
+	position := make(map[string]int)
+	for _, m := range dst.Members {
+		name := memberName(m)
+		value, exists := updated[name]
+		if _, seen := position[name]; !exists || seen {
+			continue
+		}
+		p.merge(&m.Value, value, lineIndent(m.Name.BeforeExtra, indent))
+		position[name] = len(members)
+		members = append(members, m)
+	}
+
+	for i, m := range src.Members {
+		name := memberName(m)
+		if _, seen := position[name]; seen {
+			continue
+		}
+		at := 0
+		if i > 0 {
+			at = position[memberName(src.Members[i-1])] + 1
+		}
+		member := hujson.ObjectMember{
+			Name:  hujson.Value{BeforeExtra: hujson.Extra(layout.separator), Value: m.Name.Value},
+			Value: hujson.Value{BeforeExtra: hujson.Extra(" "), Value: p.format(m.Value, layout.childIndent)},
+		}
+		members = append(members[:at], append([]hujson.ObjectMember{member}, members[at:]...)...)
+		for n, pos := range position {
+			if pos >= at {
+				position[n] = pos + 1
+			}
+		}
+		position[name] = at
+	}
+
+	dst.Members = members
+	values = values[:0]
+	for i := range members {
+		values = append(values, &members[i].Name)
+	}
+	last = nil
+	if n := len(members); n > 0 {
+		last = &members[n-1].Value
+	}
+	layout.apply(values, last, &dst.AfterExtra)
+}
+
+// mergeArray 合并数组：带 id 的对象按 id 对应（规则可以增删和调整顺序），字面量按值对应，其余按位置对应
+// 结果的顺序与新配置相同，对应上的元素连同注释一起保留
+func (p *jsoncPatcher) mergeArray(dst, src *hujson.Array, indent string) {
+	values := make([]*hujson.Value, len(dst.Elements))
+	for i := range dst.Elements {
+		values[i] = &dst.Elements[i]
+	}
+	var last *hujson.Value
+	if n := len(dst.Elements); n > 0 {
+		last = &dst.Elements[n-1]
+	}
+	layout := p.captureLayout(values, last, indent)
+
+	used := make([]bool, len(dst.Elements))
+	elements := make([]hujson.Value, 0, len(src.Elements))
+	for i, e := range src.Elements {
+		j := matchElement(dst.Elements, used, e, i)
+		if j < 0 {
+			elements = append(elements, hujson.Value{BeforeExtra: hujson.Extra(layout.separator), Value: p.format(e, layout.childIndent)})
+			continue
+		}
+		used[j] = true
+		element := dst.Elements[j]
+		p.merge(&element, e, lineIndent(element.BeforeExtra, indent))
+		elements = append(elements, element)
+	}
+
+	dst.Elements = elements
+	values = values[:0]
+	for i := range elements {
+		values = append(values, &elements[i])
+	}
+	last = nil
+	if n := len(elements); n > 0 {
+		last = &elements[n-1]
+	}
+	layout.apply(values, last, &dst.AfterExtra)
+}
+
+// compositeLayout 合并前对象或数组的排版，用于新增成员和调整成员间的空白
+type compositeLayout struct {
+	spacing       []hujson.Extra // 合并前各位置成员之前的空白（含注释的为 nil）
+	separator     string         // 新成员之前的空白
+	childIndent   string         // 新成员所在行的缩进
+	indent        string         // 对象或数组所在行的缩进
+	empty         bool           // 合并前是否为空
+	trailingComma bool           // 合并前最后一个成员后是否有逗号
+}
+
+// captureLayout 记录合并前的排版，values 为各成员（对象为成员名）的节点，last 为最后一个成员的值
+func (p *jsoncPatcher) captureLayout(values []*hujson.Value, last *hujson.Value, indent string) *compositeLayout {
+	layout := &compositeLayout{
+		separator:     "\n" + indent + p.unit,
+		childIndent:   indent + p.unit,
+		indent:        indent,
+		empty:         len(values) == 0,
+		trailingComma: last != nil && last.AfterExtra != nil,
+	}
+	for _, v := range values {
+		if isBlank(v.BeforeExtra) {
+			layout.spacing = append(layout.spacing, append(hujson.Extra{}, v.BeforeExtra...))
+		} else {
+			layout.spacing = append(layout.spacing, nil)
+		}
+	}
+
+	if n := len(values); n > 0 {
+		// 与最后一个成员保持相同的换行和缩进，单行写法的对象或数组新增成员时也保持单行
+		before := values[n-1].BeforeExtra
+		if bytes.IndexByte(before, '\n') >= 0 {
+			layout.childIndent = lineIndent(before, indent)
+			layout.separator = "\n" + layout.childIndent
+		} else {
+			layout.childIndent = indent
+			layout.separator = " "
+		}
+	}
+	return layout
+}
+
+// apply 合并后调整排版：只有空白的位置沿用该位置原来的空白，保持原有的尾随逗号写法，
+// 空对象（数组）新增成员时在结尾补上换行，删除全部成员时去掉多余的空白
+func (l *compositeLayout) apply(values []*hujson.Value, last *hujson.Value, after *hujson.Extra) {
+	for i, v := range values {
+		if !isBlank(v.BeforeExtra) {
+			continue
+		}
+		if i < len(l.spacing) && l.spacing[i] != nil {
+			v.BeforeExtra = l.spacing[i]
+		} else if i > 0 {
+			v.BeforeExtra = hujson.Extra(l.separator)
+		}
+	}
+
+	if last != nil {
+		switch {
+		case l.trailingComma && last.AfterExtra == nil:
+			last.AfterExtra = hujson.Extra{}
+		case !l.trailingComma && last.AfterExtra != nil:
+			*after = append(append(hujson.Extra{}, last.AfterExtra...), *after...)
+			last.AfterExtra = nil
+		}
+	}
+
+	switch {
+	case l.empty && last != nil && isBlank(*after) && strings.Contains(l.separator, "\n"):
+		*after = hujson.Extra("\n" + l.indent)
+	case last == nil && isBlank(*after):
+		*after = nil
+	}
+}
+
+// format 将新值按 indent 缩进格式化
+func (p *jsoncPatcher) format(v hujson.Value, indent string) hujson.ValueTrimmed {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, v.Pack(), indent, p.unit); err != nil {
+		return v.Value
+	}
+	formatted, err := hujson.Parse(buf.Bytes())
+	if err != nil {
+		return v.Value
+	}
+	return formatted.Value
+}
+
+// matchElement 在原数组中查找与新元素对应的未使用元素，找不到时返回 -1
+func matchElement(elements []hujson.Value, used []bool, e hujson.Value, index int) int {
+	if id, ok := objectID(e); ok {
+		for j, old := range elements {
+			if oldID, ok := objectID(old); !used[j] && ok && oldID == id {
+				return j
+			}
+		}
+		// 原文件中的规则还没有 id 时按位置对应
+		if index < len(elements) && !used[index] && elements[index].Value.Kind() == '{' {
+			if _, ok := objectID(elements[index]); !ok {
+				return index
+			}
+		}
+		return -1
+	}
+
+	if lit, ok := e.Value.(hujson.Literal); ok {
+		for j, old := range elements {
+			if oldLit, ok := old.Value.(hujson.Literal); !used[j] && ok && equalLiteral(oldLit, lit) {
+				return j
+			}
+		}
+		return -1
+	}
+
+	if index < len(elements) && !used[index] && elements[index].Value.Kind() == e.Value.Kind() {
+		return index
+	}
+	return -1
+}
+
+// objectID 返回对象的 id 字段
+func objectID(v hujson.Value) (string, bool) {
+	obj, ok := v.Value.(*hujson.Object)
+	if !ok {
+		return "", false
+	}
+	for _, m := range obj.Members {
+		if memberName(m) != "id" {
+			continue
+		}
+		if lit, ok := m.Value.Value.(hujson.Literal); ok && lit.Kind() == '"' {
+			return lit.String(), true
+		}
+	}
+	return "", false
+}
+
+// memberName 返回对象成员的名称
+func memberName(m hujson.ObjectMember) string {
+	if lit, ok := m.Name.Value.(hujson.Literal); ok {
+		return lit.String()
+	}
+	return ""
+}
+
+// equalLiteral 判断两个字面量是否表示相同的值，字符串按转义后的内容比较
+func equalLiteral(a, b hujson.Literal) bool {
+	if a.Kind() != b.Kind() {
+		return false
+	}
+	if a.Kind() == '"' {
+		return a.String() == b.String()
+	}
+	return bytes.Equal(a, b)
+}
+
+// lineIndent 从节点之前的空白中取出所在行的缩进，节点与前一个节点在同一行时返回 fallback
+func lineIndent(before hujson.Extra, fallback string) string {
+	i := bytes.LastIndexByte(before, '\n')
+	if i < 0 {
+		return fallback
+	}
+	rest := before[i+1:]
+	n := 0
+	for n < len(rest) && (rest[n] == ' ' || rest[n] == '\t') {
+		n++
+	}
+	return string(rest[:n])
+}
+
+// isBlank 判断是否只有空白字符，没有注释
+func isBlank(extra hujson.Extra) bool {
+	return len(bytes.TrimSpace(extra)) == 0
+}
+
+// detectIndent 取原文件第一个缩进行的缩进作为缩进单位，没有缩进行时使用两个空格
+func detectIndent(data []byte) string {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		n := 0
+		for n < len(line) && (line[n] == ' ' || line[n] == '\t') {
+			n++
+		}
+		if n > 0 && n < len(line) && line[n] != '\r' {
+			return string(line[:n])
+		}
+	}
+	return "  "
+}
@@ -0,0 +1,502 @@
+package services
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/tailscale/hujson"
+)
+
+// 配置分为以下几层，后面的层覆盖前面的层：
+// 内置默认值、系统配置文件、用户配置文件、SWITCH_INPUT_* 环境变量、命令行参数，最后是管理策略（LayerPolicy）
+// 对象按键逐层合并，其余值（包括规则等列表）由最后设置它的层整体决定
+const (
+	LayerDefault = "default" // 内置默认值
+	LayerSystem  = "system"  // 系统配置文件
+	LayerUser    = "user"    // 用户配置文件
+	LayerEnv     = "env"     // 环境变量
+	LayerFlag    = "flag"    // 命令行参数
+)
+
+// DefaultSystemConfigDir 系统配置文件所在目录，其中的 config.json（或 yaml、toml）对所有用户生效
+const DefaultSystemConfigDir = "/etc/switch-input"
+
+// envPrefix 覆盖配置项的环境变量前缀
+const envPrefix = "SWITCH_INPUT_"
+
+// overrideSetting 可以通过环境变量和命令行参数覆盖的配置项
+type overrideSetting struct {
+	path string       // 配置项位置，例如 general.logLevel
+	kind reflect.Kind // Bool / Int / String / Slice（字符串列表，用逗号分隔）
+}
+
+// overrideSettings 列出可以覆盖的配置项：activeProfile 以及 general 中的开关、数值、字符串和字符串列表
+func overrideSettings() []overrideSetting {
+	settings := []overrideSetting{{path: "activeProfile", kind: reflect.String}}
+
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			switch kind := field.Type.Kind(); kind {
+			case reflect.Struct:
+				walk(field.Type, prefix+name+".")
+			case reflect.Bool, reflect.Int, reflect.String:
+				settings = append(settings, overrideSetting{path: prefix + name, kind: kind})
+			case reflect.Slice:
+				if field.Type.Elem().Kind() == reflect.String {
+					settings = append(settings, overrideSetting{path: prefix + name, kind: kind})
+				}
+			}
+		}
+	}
+	walk(reflect.TypeOf(GeneralConfig{}), "general.")
+	return settings
+}
+
+// words 将配置项位置拆分为单词，general 前缀省略，例如 general.fallback.mode -> fallback mode
+func (s overrideSetting) words() []string {
+	var words []string
+	for _, part := range strings.Split(strings.TrimPrefix(s.path, "general."), ".") {
+		start := 0
+		for i, r := range part {
+			if i > 0 && unicode.IsUpper(r) {
+				words = append(words, strings.ToLower(part[start:i]))
+				start = i
+			}
+		}
+		words = append(words, strings.ToLower(part[start:]))
+	}
+	return words
+}
+
+// envName 返回对应的环境变量名，例如 general.logLevel -> SWITCH_INPUT_LOG_LEVEL
+func (s overrideSetting) envName() string {
+	return envPrefix + strings.ToUpper(strings.Join(s.words(), "_"))
+}
+
+// flagName 返回对应的命令行参数名，例如 general.logLevel -> log-level
+func (s overrideSetting) flagName() string {
+	return strings.Join(s.words(), "-")
+}
+
+// parse 将环境变量或命令行参数的文本转换为配置值
+func (s overrideSetting) parse(raw string) (interface{}, error) {
+	switch s.kind {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean %q", raw)
+		}
+		return b, nil
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", raw)
+		}
+		return float64(n), nil
+	case reflect.Slice:
+		list := []interface{}{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list, nil
+	default:
+		return raw, nil
+	}
+}
+
+// EnvOverrides 读取 SWITCH_INPUT_* 环境变量，返回配置项位置 -> 文本值
+// 例如 SWITCH_INPUT_LOG_LEVEL=debug 覆盖 general.logLevel；无法识别的变量返回错误，其余变量仍然有效
+func EnvOverrides(environ []string) (map[string]string, error) {
+	byName := make(map[string]string)
+	for _, s := range overrideSettings() {
+		byName[s.envName()] = s.path
+	}
+
+	values := make(map[string]string)
+	var unknown []string
+	for _, entry := range environ {
+		name, value, found := strings.Cut(entry, "=")
+		if !found || !strings.HasPrefix(name, envPrefix) {
+			continue
+		}
+		path, exists := byName[name]
+		if !exists {
+			unknown = append(unknown, name)
+			continue
+		}
+		values[path] = value
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return values, fmt.Errorf("unknown environment variables: %s", strings.Join(unknown, ", "))
+	}
+	return values, nil
+}
+
+// overrideFlag 覆盖配置项的命令行参数，解析参数时即按配置项的类型检查取值
+type overrideFlag struct {
+	setting overrideSetting
+	raw     string
+}
+
+// String 返回参数的文本值
+func (f *overrideFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.raw
+}
+
+// Set 检查并记录参数的文本值，取值无效时作为参数解析错误报告
+func (f *overrideFlag) Set(raw string) error {
+	if _, err := f.setting.parse(raw); err != nil {
+		return err
+	}
+	f.raw = raw
+	return nil
+}
+
+// IsBoolFlag 开关类配置项可以省略取值，例如 -enable-logging 等同于 -enable-logging=true
+func (f *overrideFlag) IsBoolFlag() bool {
+	return f.setting.kind == reflect.Bool
+}
+
+// RegisterOverrideFlags 为每个可以覆盖的配置项注册命令行参数，例如 -log-level debug
+// 参数按配置项的类型解析：开关可以省略取值，数值参数不是整数时解析参数失败
+// 返回的函数在解析参数后调用，得到显式指定的配置项位置 -> 文本值
+func RegisterOverrideFlags(flags *flag.FlagSet) func() map[string]string {
+	byFlag := make(map[string]string)
+	for _, s := range overrideSettings() {
+		byFlag[s.flagName()] = s.path
+		flags.Var(&overrideFlag{setting: s}, s.flagName(), fmt.Sprintf("覆盖配置项 %s", s.path))
+	}
+
+	return func() map[string]string {
+		values := make(map[string]string)
+		flags.Visit(func(f *flag.Flag) {
+			if path, exists := byFlag[f.Name]; exists {
+				values[path] = f.Value.String()
+			}
+		})
+		return values
+	}
+}
+
+// SetSystemConfigDir 设置系统配置文件所在目录，为空表示不使用系统配置
+func (ms *MatcherService) SetSystemConfigDir(dir string) {
+	ms.ruleMutex.Lock()
+	defer ms.ruleMutex.Unlock()
+	ms.systemConfigDir = dir
+}
+
+// SetOverrides 设置环境变量层和命令行参数层，参数为配置项位置 -> 文本值，在下一次加载配置时生效
+// 无效的值会被忽略并返回错误，其余的值仍然生效
+func (ms *MatcherService) SetOverrides(env, flags map[string]string) error {
+	settings := make(map[string]overrideSetting)
+	for _, s := range overrideSettings() {
+		settings[s.path] = s
+	}
+
+	var errs []string
+	build := func(values map[string]string, describe func(overrideSetting) string) map[string]interface{} {
+		layer := make(map[string]interface{})
+		paths := make([]string, 0, len(values))
+		for path := range values {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			s, exists := settings[path]
+			if !exists {
+				errs = append(errs, fmt.Sprintf("%s: cannot be overridden", path))
+				continue
+			}
+			value, err := s.parse(values[path])
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", describe(s), err))
+				continue
+			}
+			setLayerValue(layer, path, value)
+		}
+		return layer
+	}
+	envLayer := build(env, overrideSetting.envName)
+	flagLayer := build(flags, func(s overrideSetting) string { return "-" + s.flagName() })
+
+	ms.ruleMutex.Lock()
+	ms.envLayer = envLayer
+	ms.flagLayer = flagLayer
+	ms.ruleMutex.Unlock()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config overrides: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// setLayerValue 按配置项位置在层中设置值，中间的对象不存在时创建
+func setLayerValue(layer map[string]interface{}, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := layer[part].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			layer[part] = next
+		}
+		layer = next
+	}
+	layer[parts[len(parts)-1]] = value
+}
+
+// defaultLayer 返回内置默认值层
+func defaultLayer() map[string]interface{} {
+	var config Config
+	applyConfigDefaults(&config)
+	layer := toLayer(config)
+	// 版本和修改时间描述的是配置文件本身，不属于默认值
+	delete(layer, "version")
+	delete(layer, "lastModified")
+	return layer
+}
+
+// toLayer 将值转换为 JSON 对象形式的层
+func toLayer(value interface{}) map[string]interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var layer map[string]interface{}
+	json.Unmarshal(data, &layer)
+	return layer
+}
+
+// systemLayerLocked 读取系统配置文件，文件不存在时返回 nil，调用方需持有锁
+func (ms *MatcherService) systemLayerLocked() (map[string]interface{}, error) {
+	if ms.systemConfigDir == "" {
+		return nil, nil
+	}
+	path := FindConfigFile(ms.systemConfigDir)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read system config %s: %v", path, err)
+	}
+
+	converted, err := decodeConfigData(data, configFormat(path))
+	if err != nil {
+		return nil, fmt.Errorf("system config %s: %v", path, err)
+	}
+	migrated, _, err := migrateConfigData(converted)
+	if err != nil {
+		return nil, fmt.Errorf("system config %s: %v", path, err)
+	}
+	var layer map[string]interface{}
+	if err := json.Unmarshal(migrated, &layer); err != nil {
+		return nil, fmt.Errorf("system config %s: %v", path, err)
+	}
+	delete(layer, "version")
+	delete(layer, "lastModified")
+	return layer, nil
+}
+
+// mergeLayer 将 layer 合并到 dst：对象按键合并，其余值整体替换，并在 sources 中记录每个值来自哪一层
+// dst 中的对象都是新建的，不会修改 layer
+func mergeLayer(dst, layer map[string]interface{}, name, prefix string, sources map[string]string) {
+	for key, value := range layer {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		if sub, ok := value.(map[string]interface{}); ok {
+			existing, ok := dst[key].(map[string]interface{})
+			if !ok {
+				clearSources(sources, path)
+				existing = make(map[string]interface{})
+				dst[key] = existing
+				sources[path] = name
+			}
+			mergeLayer(existing, sub, name, path, sources)
+			continue
+		}
+
+		clearSources(sources, path)
+		dst[key] = value
+		sources[path] = name
+	}
+}
+
+// clearSources 删除配置项及其下级配置项的来源记录
+func clearSources(sources map[string]string, path string) {
+	for p := range sources {
+		if p == path || strings.HasPrefix(p, path+".") {
+			delete(sources, p)
+		}
+	}
+}
+
+// buildConfigLocked 将用户配置（已升级到当前版本的 JSON）与其他各层合并后解析并校验，调用方需持有锁
+func (ms *MatcherService) buildConfigLocked(user []byte, includes []RuleSource) (*Config, *compiledConfig, error) {
+	var userLayer map[string]interface{}
+	if err := json.Unmarshal(user, &userLayer); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config: %s", describeJSONError(user, err))
+	}
+	systemLayer, err := ms.systemLayerLocked()
+	if err != nil {
+		return nil, nil, err
+	}
+	policy, err := ms.policyLocked()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	merged := make(map[string]interface{})
+	sources := make(map[string]string)
+	mergeLayer(merged, defaultLayer(), LayerDefault, "", sources)
+	mergeLayer(merged, systemLayer, LayerSystem, "", sources)
+	mergeLayer(merged, userLayer, LayerUser, "", sources)
+	mergeLayer(merged, ms.envLayer, LayerEnv, "", sources)
+	mergeLayer(merged, ms.flagLayer, LayerFlag, "", sources)
+
+	// 锁定的配置项只使用默认值和系统配置中的值，其中强制的配置项再由管理策略覆盖
+	locked := policy.lockedPaths()
+	if policy != nil {
+		lower := make(map[string]interface{})
+		lowerSources := make(map[string]string)
+		mergeLayer(lower, defaultLayer(), LayerDefault, "", lowerSources)
+		mergeLayer(lower, systemLayer, LayerSystem, "", lowerSources)
+		lockLayer(merged, lower, lowerSources, locked, sources)
+		mergeLayer(merged, policy.Settings, LayerPolicy, "", sources)
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to merge config layers: %v", err)
+	}
+	config, compiled, err := parseConfig(data, withPolicyRules(includes, policy))
+	if err != nil {
+		return nil, nil, err
+	}
+	config.ValueSources = sources
+	config.Locked = locked
+	config.markLockedRules()
+	return config, compiled, nil
+}
+
+// userLayerDataLocked 从完整配置 full 中取出需要写入用户配置文件的部分，original 为用户配置文件原来的内容：
+// 与下层（默认值和系统配置）相同、且原文件中没有的配置项不写入；新建配置文件时写入所有默认值便于编辑，
+// 只省略来自系统配置的值；由环境变量或命令行参数决定的配置项保留原文件中的值，只有被修改为其他值时才写入新值；
+// 被管理策略锁定的配置项始终保留原文件中的值。
+// 调用方需持有锁
+func (ms *MatcherService) userLayerDataLocked(full []byte, original map[string]interface{}) ([]byte, error) {
+	root, err := hujson.Parse(full)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config: %v", err)
+	}
+	obj, ok := root.Value.(*hujson.Object)
+	if !ok {
+		return full, nil
+	}
+
+	systemLayer, err := ms.systemLayerLocked()
+	if err != nil {
+		return nil, err
+	}
+	lower := make(map[string]interface{})
+	if original != nil {
+		mergeLayer(lower, defaultLayer(), LayerDefault, "", make(map[string]string))
+	}
+	mergeLayer(lower, systemLayer, LayerSystem, "", make(map[string]string))
+
+	overrides := make(map[string]interface{})
+	mergeLayer(overrides, ms.envLayer, LayerEnv, "", make(map[string]string))
+	mergeLayer(overrides, ms.flagLayer, LayerFlag, "", make(map[string]string))
+
+	policy, err := ms.policyLocked()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range policy.lockedPaths() {
+		restoreLockedValue(obj, path, original)
+	}
+
+	pruneUserLayer(obj, lower, original, overrides)
+	return root.Pack(), nil
+}
+
+// pruneUserLayer 按 userLayerDataLocked 的规则删除对象中不需要写入用户配置文件的成员
+func pruneUserLayer(obj *hujson.Object, lower, user, overrides map[string]interface{}) {
+	members := obj.Members[:0]
+	for _, m := range obj.Members {
+		name := memberName(m)
+		var value interface{}
+		if err := json.Unmarshal(m.Value.Pack(), &value); err != nil {
+			members = append(members, m)
+			continue
+		}
+		userValue, inUser := user[name]
+
+		if override, exists := overrides[name]; exists {
+			if _, isObject := override.(map[string]interface{}); !isObject {
+				if reflect.DeepEqual(value, override) {
+					if !inUser {
+						continue
+					}
+					data, _ := json.Marshal(userValue)
+					if original, err := hujson.Parse(data); err == nil {
+						m.Value.Value = original.Value
+					}
+				}
+				members = append(members, m)
+				continue
+			}
+		}
+
+		if sub, ok := m.Value.Value.(*hujson.Object); ok {
+			subLower, _ := lower[name].(map[string]interface{})
+			subUser, _ := userValue.(map[string]interface{})
+			subOverrides, _ := overrides[name].(map[string]interface{})
+			pruneUserLayer(sub, subLower, subUser, subOverrides)
+			if len(sub.Members) == 0 && !inUser && len(value.(map[string]interface{})) > 0 {
+				continue
+			}
+			members = append(members, m)
+			continue
+		}
+
+		if inUser || !reflect.DeepEqual(value, lower[name]) {
+			members = append(members, m)
+		}
+	}
+	obj.Members = members
+}
+
+// ValueSource 返回配置项的值来自哪一层（LayerDefault 等），例如 general.logLevel、rules[2].app
+// 列表中的元素与整个列表的来源相同；没有记录时返回空字符串
+func (c *Config) ValueSource(path string) string {
+	for p := path; p != ""; p = parentPath(p) {
+		if source, exists := c.ValueSources[p]; exists {
+			return source
+		}
+	}
+	return ""
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegisterOverrideFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "bool flag without a value",
+			args: []string{"-enable-logging"},
+			want: map[string]string{"general.enableLogging": "true"},
+		},
+		{
+			name: "bool flag with a value",
+			args: []string{"-enable-logging=false", "-log-level", "debug"},
+			want: map[string]string{"general.enableLogging": "false", "general.logLevel": "debug"},
+		},
+		{
+			name: "int and list flags",
+			args: []string{"-check-interval", "250", "-ignore-apps", "Raycast,Alfred", "-fallback-mode=restore"},
+			want: map[string]string{"general.checkInterval": "250", "general.ignoreApps": "Raycast,Alfred", "general.fallback.mode": "restore"},
+		},
+		{
+			name:    "invalid int",
+			args:    []string{"-check-interval=abc"},
+			wantErr: `invalid value "abc" for flag -check-interval: invalid integer "abc"`,
+		},
+		{
+			name:    "invalid bool",
+			args:    []string{"-enable-logging=maybe"},
+			wantErr: `invalid boolean value "maybe" for -enable-logging`,
+		},
+		{
+			name:    "missing value",
+			args:    []string{"-check-interval"},
+			wantErr: "flag needs an argument: -check-interval",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := flag.NewFlagSet("switch-input", flag.ContinueOnError)
+			flags.SetOutput(ioutil.Discard)
+			values := RegisterOverrideFlags(flags)
+
+			err := flags.Parse(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Parse(%v) error = %v, want %q", tt.args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%v): %v", tt.args, err)
+			}
+			if got := values(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("values = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverrideLayers(t *testing.T) {
+	env, err := EnvOverrides([]string{"SWITCH_INPUT_LOG_LEVEL=warn", "SWITCH_INPUT_CHECK_INTERVAL=300", "HOME=/root"})
+	if err != nil {
+		t.Fatalf("EnvOverrides: %v", err)
+	}
+	if _, err := EnvOverrides([]string{"SWITCH_INPUT_NO_SUCH_SETTING=1"}); err == nil {
+		t.Error("EnvOverrides accepted an unknown variable")
+	}
+
+	ms, err := loadTestConfig(t, map[string]interface{}{
+		"version": currentConfigVersion,
+		"general": map[string]interface{}{"logLevel": "info", "checkInterval": 100, "enableLogging": false},
+		"rules":   []Rule{testRule("terminal", "Terminal", MatchExact, 1)},
+	})
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if err := ms.SetOverrides(env, map[string]string{"general.checkInterval": "400", "general.enableLogging": "true"}); err != nil {
+		t.Fatalf("SetOverrides: %v", err)
+	}
+	if err := ms.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	config := ms.GetConfig()
+	tests := []struct {
+		path   string
+		value  interface{}
+		source string
+	}{
+		{"general.logLevel", config.General.LogLevel, LayerEnv},
+		{"general.checkInterval", config.General.CheckInterval, LayerFlag},
+		{"general.enableLogging", config.General.EnableLogging, LayerFlag},
+		{"general.switchDelay", config.General.SwitchDelay, LayerDefault},
+	}
+	want := map[string]interface{}{
+		"general.logLevel":      "warn",
+		"general.checkInterval": 400,
+		"general.enableLogging": true,
+	}
+	for _, tt := range tests {
+		if w, exists := want[tt.path]; exists && tt.value != w {
+			t.Errorf("%s = %v, want %v", tt.path, tt.value, w)
+		}
+		if got := config.ValueSource(tt.path); got != tt.source {
+			t.Errorf("ValueSource(%s) = %q, want %q", tt.path, got, tt.source)
+		}
+	}
+
+	// 覆盖的值不写入用户配置文件
+	if _, err := ms.AddRule(Rule{AppName: "Safari", Input: InputList{"com.apple.keylayout.ABC"}, Enabled: true}); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	written, err := ioutil.ReadFile(ms.configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(written), "400") || strings.Contains(string(written), "warn") {
+		t.Errorf("overridden values were written to the config file:\n%s", written)
+	}
+
+	if err := ms.SetOverrides(nil, map[string]string{"general.checkInterval": "abc", "rules": "x"}); err == nil {
+		t.Error("SetOverrides accepted invalid values")
+	}
+}
@@ -0,0 +1,436 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// 规则检查发现的问题类型
+const (
+	LintDuplicate    = "duplicate"     // 与另一条规则的匹配条件和目标输入法完全相同
+	LintShadowed     = "shadowed"      // 能匹配的窗口总是被另一条规则抢先匹配，规则永远不会生效
+	LintConflict     = "conflict"      // 与另一条规则的匹配范围重叠，但目标输入法不同
+	LintEmptyPattern = "empty-pattern" // 空的应用名称或排除条件
+	LintUnknownInput = "unknown-input" // 目标输入法不在可用输入法中
+)
+
+// LintIssue 规则检查发现的问题
+type LintIssue struct {
+	Kind      string `json:"kind"`                // 问题类型，例如 shadowed
+	Severity  string `json:"severity"`            // error / warning
+	Path      string `json:"path"`                // 规则或配置项的位置，例如 rules[2]、rules[2].app
+	RuleID    string `json:"ruleId,omitempty"`    // 规则ID
+	Related   string `json:"related,omitempty"`   // 相关规则的位置
+	RelatedID string `json:"relatedId,omitempty"` // 相关规则的ID
+	Message   string `json:"message"`             // 问题说明
+}
+
+// String 返回 "位置 (ID): 级别: 类型: 说明" 形式的文本
+func (li LintIssue) String() string {
+	var b strings.Builder
+	b.WriteString(li.Path)
+	if li.RuleID != "" {
+		fmt.Fprintf(&b, " (%s)", li.RuleID)
+	}
+	fmt.Fprintf(&b, ": %s: %s: %s", li.Severity, li.Kind, li.Message)
+	return b.String()
+}
+
+// LintResult 规则检查结果
+type LintResult struct {
+	Issues []LintIssue `json:"issues"` // 所有问题，按规则在配置中的顺序排列
+}
+
+// lintRule 参与检查的规则
+type lintRule struct {
+	cr     compiledRule
+	inputs InputList // 展开别名后的目标输入法
+}
+
+// LintConfig 检查配置中的规则：重复的规则、被其他规则完全遮蔽而永远不会生效的规则、
+// 匹配范围重叠但目标输入法不同的规则、空的匹配模式，以及不可用的输入法
+// availableInputs 为可用的输入法ID，为 nil 时跳过输入法是否可用的检查；已禁用的规则不参与检查
+func LintConfig(config *Config, availableInputs []string) *LintResult {
+	result := &LintResult{Issues: []LintIssue{}}
+
+	var rules []lintRule
+	for i, ref := range config.ruleRefs() {
+		rule := *config.rule(ref)
+		if !rule.Enabled {
+			continue
+		}
+		result.Issues = append(result.Issues, lintPatterns(rule, ref)...)
+		result.Issues = append(result.Issues, lintInputs(config, rule, ref, availableInputs)...)
+
+		// 无效的规则由配置校验报告
+		cr, errs := compileRule(rule, ref, i)
+		if len(errs) > 0 {
+			continue
+		}
+		rules = append(rules, lintRule{cr: cr, inputs: config.resolveInputs(rule.Input)})
+	}
+
+	// 每条规则只报告与之相关的第一个重复或遮蔽，冲突按规则对报告在后面的规则上
+	for j := range rules {
+		b := &rules[j]
+		reported := false
+		for i := range rules {
+			if i == j {
+				continue
+			}
+			a := &rules[i]
+			if i < j && sameConditions(&a.cr, &b.cr) && reflect.DeepEqual(a.inputs, b.inputs) {
+				result.Issues = append(result.Issues, pairIssue(LintDuplicate, b, a,
+					fmt.Sprintf("duplicates %s: same conditions and inputs", a.cr.ref.location)))
+				reported = true
+				break
+			}
+			if len(b.cr.apps) > 0 && shadows(&a.cr, &b.cr) {
+				message := fmt.Sprintf("never applies: every window it matches is taken by %s", a.cr.ref.location)
+				if !reflect.DeepEqual(a.inputs, b.inputs) {
+					message += fmt.Sprintf(", which switches to %s instead of %s", a.inputs, b.inputs)
+				}
+				result.Issues = append(result.Issues, pairIssue(LintShadowed, b, a, message))
+				reported = true
+				break
+			}
+		}
+		if reported {
+			continue
+		}
+
+		for i := 0; i < j; i++ {
+			a := &rules[i]
+			if reflect.DeepEqual(a.inputs, b.inputs) || !mayConflict(&a.cr, &b.cr) {
+				continue
+			}
+			result.Issues = append(result.Issues, pairIssue(LintConflict, b, a,
+				fmt.Sprintf("overlaps with %s but switches to %s instead of %s; windows matching both use the rule with the higher score or priority", a.cr.ref.location, b.inputs, a.inputs)))
+		}
+	}
+
+	sortLintIssues(config, result.Issues)
+	return result
+}
+
+// LintConfig 检查当前加载的配置中的规则，包括各配置方案、rules.d 和管理策略中的规则
+func (ms *MatcherService) LintConfig(availableInputs []string) (*LintResult, error) {
+	snapshot := ms.current()
+	if snapshot == nil {
+		return nil, fmt.Errorf("config not loaded")
+	}
+	return LintConfig(snapshot.config, availableInputs), nil
+}
+
+// LintConfigFile 检查配置文件中的规则，同时加载同一目录下 rules.d 中的规则文件
+// 配置文件无效时返回错误，具体问题可以通过 ValidateConfigFile 获得
+func LintConfigFile(path string, data []byte, availableInputs []string) (*LintResult, error) {
+	converted, err := decodeConfigData(data, configFormat(path))
+	if err != nil {
+		return nil, err
+	}
+	migrated, _, err := migrateConfigData(converted)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := loadRuleSources(filepath.Join(filepath.Dir(path), ruleSourceDirName))
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := parseConfig(migrated, sources)
+	if err != nil {
+		return nil, err
+	}
+	return LintConfig(config, availableInputs), nil
+}
+
+// pairIssue 生成与另一条规则相关的问题，报告在规则 b 上
+func pairIssue(kind string, b, a *lintRule, message string) LintIssue {
+	return LintIssue{
+		Kind:      kind,
+		Severity:  SeverityWarning,
+		Path:      b.cr.ref.location,
+		RuleID:    b.cr.rule.ID,
+		Related:   a.cr.ref.location,
+		RelatedID: a.cr.rule.ID,
+		Message:   message,
+	}
+}
+
+// lintPatterns 检查空的应用名称和排除条件
+func lintPatterns(rule Rule, ref ruleRef) []LintIssue {
+	var issues []LintIssue
+	add := func(path, message string) {
+		issues = append(issues, LintIssue{Kind: LintEmptyPattern, Severity: SeverityWarning, Path: path, RuleID: rule.ID, Message: message})
+	}
+
+	if strings.TrimSpace(rule.AppName) == "" {
+		add(ref.location+".app", "app is empty, the rule never matches")
+	} else if rule.AppMatch != MatchRegex {
+		for _, name := range strings.Split(rule.AppName, ",") {
+			if strings.TrimSpace(name) == "" {
+				add(ref.location+".app", "app list contains an empty name (extra comma)")
+				break
+			}
+		}
+	}
+
+	if rule.Exclude != nil {
+		fields := []struct {
+			name   string
+			values []string
+		}{
+			{"app", rule.Exclude.App},
+			{"window", rule.Exclude.Window},
+			{"appPath", rule.Exclude.AppPath},
+		}
+		for _, field := range fields {
+			for j, value := range field.values {
+				if strings.TrimSpace(value) != "" || rule.Exclude.Match == MatchExact {
+					continue
+				}
+				add(fmt.Sprintf("%s.exclude.%s[%d]", ref.location, field.name, j), "empty exclude pattern matches every window, the rule never applies")
+			}
+		}
+	}
+	return issues
+}
+
+// lintInputs 检查规则的目标输入法是否可用
+func lintInputs(config *Config, rule Rule, ref ruleRef, availableInputs []string) []LintIssue {
+	if availableInputs == nil {
+		return nil
+	}
+	available := make(map[string]bool)
+	for _, inputID := range availableInputs {
+		available[inputID] = true
+	}
+
+	var issues []LintIssue
+	for _, inputID := range config.resolveInputs(rule.Input) {
+		if strings.TrimSpace(inputID) == "" || available[inputID] {
+			continue
+		}
+		issues = append(issues, LintIssue{
+			Kind:     LintUnknownInput,
+			Severity: SeverityWarning,
+			Path:     ref.location + ".input",
+			RuleID:   rule.ID,
+			Message:  fmt.Sprintf("input %q is not among the available inputs", inputID),
+		})
+	}
+	return issues
+}
+
+// sortLintIssues 按规则在配置中的顺序排列问题，同一规则的问题保持发现的顺序
+func sortLintIssues(config *Config, issues []LintIssue) {
+	order := make(map[string]int)
+	for i, ref := range config.ruleRefs() {
+		order[ref.location] = i
+	}
+	position := func(path string) int {
+		for p := path; p != ""; p = parentPath(p) {
+			if i, exists := order[p]; exists {
+				return i
+			}
+		}
+		return len(order)
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		return position(issues[i].Path) < position(issues[j].Path)
+	})
+}
+
+// sameConditions 判断两条规则在同一范围内且匹配条件完全相同
+func sameConditions(a, b *compiledRule) bool {
+	if a.ref.profile != b.ref.profile || a.fuzzy != b.fuzzy || len(a.apps) != len(b.apps) {
+		return false
+	}
+	if !samePatterns(a.apps, b.apps) {
+		return false
+	}
+	if (a.window == nil) != (b.window == nil) || (a.window != nil && !samePattern(a.window, b.window)) {
+		return false
+	}
+	return reflect.DeepEqual(a.rule.Schedule, b.rule.Schedule) && reflect.DeepEqual(a.rule.Exclude, b.rule.Exclude)
+}
+
+// samePatterns 判断两组模式是否相同，与顺序和大小写无关
+func samePatterns(a, b []*pattern) bool {
+	key := func(patterns []*pattern) []string {
+		keys := make([]string, len(patterns))
+		for i, p := range patterns {
+			keys[i] = p.mode + ":" + p.lower
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	return reflect.DeepEqual(key(a), key(b))
+}
+
+// samePattern 判断两个模式是否相同（忽略大小写）
+func samePattern(a, b *pattern) bool {
+	return a.mode == b.mode && a.lower == b.lower
+}
+
+// shadows 判断规则 a 是否完全遮蔽规则 b：b 能匹配的每个窗口 a 都能匹配，且总是 a 被选中
+// 只在能够确定时返回 true，无法判断的模式（例如两个不同的正则表达式）视为不遮蔽
+func shadows(a, b *compiledRule) bool {
+	// 配置方案中的规则只在方案启用时参与匹配，不能遮蔽共享规则或其他方案的规则
+	if a.ref.profile != "" && a.ref.profile != b.ref.profile {
+		return false
+	}
+	// a 的排除条件和生效时间会留下 b 生效的机会
+	if a.rule.Exclude != nil && !reflect.DeepEqual(a.rule.Exclude, b.rule.Exclude) {
+		return false
+	}
+	if a.rule.Schedule != nil && !reflect.DeepEqual(a.rule.Schedule, b.rule.Schedule) {
+		return false
+	}
+	if b.window != nil && a.window != nil && !patternCovers(a.window, b.window) {
+		return false
+	}
+	if b.window == nil && a.window != nil {
+		return false
+	}
+
+	aPolicy, bPolicy := a.ref.source == policyRuleSource, b.ref.source == policyRuleSource
+	if bPolicy && !aPolicy {
+		return false
+	}
+	always := aPolicy && !bPolicy
+	tie := ruleEvaluation{cr: a}.better(ruleEvaluation{cr: b})
+	bonusA, bonusB := windowBonus(a), windowBonus(b)
+	wins := func(scoreA, scoreB int) bool {
+		scoreA += bonusA
+		scoreB += bonusB
+		return always || scoreA > scoreB || (scoreA == scoreB && tie)
+	}
+
+	for _, pb := range b.apps {
+		if pb.mode == MatchExact {
+			// 具体的应用名称：直接比较两条规则对它的得分
+			scoreA, _, _ := a.appScore(pb.raw)
+			scoreB, _, _ := b.appScore(pb.raw)
+			if scoreA == 0 || !wins(scoreA, scoreB) {
+				return false
+			}
+			// b 还会模糊匹配包含该名称的其他应用，需要 a 以同样的方式匹配
+			if b.fuzzy && (!a.fuzzy || !hasPattern(a.apps, pb) || !wins(scoreFuzzy, scoreFuzzy)) {
+				return false
+			}
+			continue
+		}
+
+		covered := false
+		for _, pa := range a.apps {
+			if patternCovers(pa, pb) && wins(modeScore(pa.mode), modeScore(pb.mode)) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// windowBonus 返回规则的窗口条件带来的额外得分
+func windowBonus(cr *compiledRule) int {
+	if cr.window != nil {
+		return scoreWindowMatch
+	}
+	return 0
+}
+
+// hasPattern 判断模式列表中是否有与 p 相同的模式
+func hasPattern(patterns []*pattern, p *pattern) bool {
+	for _, candidate := range patterns {
+		if samePattern(candidate, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// patternCovers 判断模式 a 是否能匹配模式 b 能匹配的所有值，无法判断时返回 false
+func patternCovers(a, b *pattern) bool {
+	if samePattern(a, b) {
+		return true
+	}
+	if b.mode == MatchExact {
+		return a.match(b.raw)
+	}
+	switch a.mode {
+	case MatchPrefix:
+		return b.mode == MatchPrefix && strings.HasPrefix(b.lower, a.lower)
+	case MatchContains:
+		return (b.mode == MatchPrefix || b.mode == MatchContains) && strings.Contains(b.lower, a.lower)
+	case MatchGlob:
+		return strings.Trim(a.raw, "*") == ""
+	}
+	return false
+}
+
+// patternsOverlap 判断是否确定存在同时匹配两个模式的值，无法判断时返回 false
+func patternsOverlap(a, b *pattern) bool {
+	if samePattern(a, b) {
+		return true
+	}
+	if a.mode == MatchExact {
+		return b.match(a.raw)
+	}
+	if b.mode == MatchExact {
+		return a.match(b.raw)
+	}
+	switch {
+	case a.mode == MatchPrefix && b.mode == MatchPrefix:
+		return strings.HasPrefix(a.lower, b.lower) || strings.HasPrefix(b.lower, a.lower)
+	case (a.mode == MatchPrefix || a.mode == MatchContains) && (b.mode == MatchPrefix || b.mode == MatchContains):
+		// 前缀加上另一个子串构成的值同时匹配两者
+		return true
+	}
+	return patternCovers(a, b) || patternCovers(b, a)
+}
+
+// mayConflict 判断两条规则是否确定会同时匹配某些窗口
+// 配置方案中的规则有意覆盖共享规则，不同范围的规则不视为冲突；生效时间不同的规则可能不会同时生效，同样跳过
+func mayConflict(a, b *compiledRule) bool {
+	if a.ref.profile != b.ref.profile {
+		return false
+	}
+	if a.rule.Schedule != nil && b.rule.Schedule != nil && !reflect.DeepEqual(a.rule.Schedule, b.rule.Schedule) {
+		return false
+	}
+	if a.window != nil && b.window != nil && !patternsOverlap(a.window, b.window) {
+		return false
+	}
+
+	for _, pa := range a.apps {
+		for _, pb := range b.apps {
+			if patternsOverlap(pa, pb) {
+				return true
+			}
+		}
+	}
+	// 模糊匹配：一条规则的具体名称被另一条规则模糊匹配
+	for _, pa := range a.apps {
+		if pa.mode == MatchExact && b.fuzzy {
+			if score, _, _ := b.appScore(pa.raw); score > 0 {
+				return true
+			}
+		}
+	}
+	for _, pb := range b.apps {
+		if pb.mode == MatchExact && a.fuzzy {
+			if score, _, _ := a.appScore(pb.raw); score > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLintConfig(t *testing.T) {
+	withWindow := func(rule Rule, window string) Rule {
+		rule.WindowName = window
+		return rule
+	}
+	withExclude := func(rule Rule, exclude *RuleExclude) Rule {
+		rule.Exclude = exclude
+		return rule
+	}
+	disabled := testRule("disabled", "Terminal", MatchExact, 1)
+	disabled.Enabled = false
+
+	tests := []struct {
+		name      string
+		rules     []Rule
+		available []string
+		kind      string // 期望的问题类型，为空时不应有任何问题
+		path      string
+		related   string
+	}{
+		{
+			name: "duplicate",
+			rules: []Rule{
+				testRule("first", "Terminal", MatchExact, 1),
+				{ID: "second", AppName: "terminal", AppMatch: MatchExact, Input: InputList{"com.test.first"}, Enabled: true, Priority: 1},
+			},
+			kind:    LintDuplicate,
+			path:    "rules[1]",
+			related: "rules[0]",
+		},
+		{
+			name: "same exact pattern with a different input is shadowed",
+			rules: []Rule{
+				testRule("first", "Terminal", MatchExact, 1),
+				testRule("second", "Terminal", MatchExact, 2),
+			},
+			kind:    LintShadowed,
+			path:    "rules[1]",
+			related: "rules[0]",
+		},
+		{
+			name: "shorter prefix shadows a longer one",
+			rules: []Rule{
+				testRule("term", "Term", MatchPrefix, 1),
+				testRule("terminal", "Terminal", MatchPrefix, 2),
+			},
+			kind:    LintShadowed,
+			path:    "rules[1]",
+			related: "rules[0]",
+		},
+		{
+			name: "same pattern with a window condition conflicts",
+			rules: []Rule{
+				testRule("code", "Code", MatchExact, 1),
+				withWindow(testRule("docs", "Code", MatchExact, 1), "README"),
+			},
+			kind:    LintConflict,
+			path:    "rules[1]",
+			related: "rules[0]",
+		},
+		{
+			name:  "empty app",
+			rules: []Rule{testRule("empty", " ", MatchExact, 1)},
+			kind:  LintEmptyPattern,
+			path:  "rules[0].app",
+		},
+		{
+			name:  "extra comma in the app list",
+			rules: []Rule{testRule("list", "Terminal,,iTerm2", MatchExact, 1)},
+			kind:  LintEmptyPattern,
+			path:  "rules[0].app",
+		},
+		{
+			name:  "empty exclude pattern",
+			rules: []Rule{withExclude(testRule("excluded", "Terminal", MatchExact, 1), &RuleExclude{Window: []string{"ssh", ""}})},
+			kind:  LintEmptyPattern,
+			path:  "rules[0].exclude.window[1]",
+		},
+		{
+			name:      "unknown input",
+			rules:     []Rule{testRule("terminal", "Terminal", MatchExact, 1)},
+			available: []string{"com.apple.keylayout.ABC"},
+			kind:      LintUnknownInput,
+			path:      "rules[0].input",
+		},
+		{
+			// 精确匹配的得分高于前缀匹配，前缀规则不会遮蔽精确规则
+			name: "prefix does not shadow a more specific exact rule",
+			rules: []Rule{
+				testRule("term", "Term", MatchPrefix, 1),
+				testRule("terminal", "Terminal", MatchExact, 2),
+				testRule("safari", "Safari", MatchExact, 1),
+			},
+			available: []string{"com.test.term", "com.test.terminal", "com.test.safari"},
+			kind:      LintConflict,
+			path:      "rules[1]",
+			related:   "rules[0]",
+		},
+		{
+			name: "clean config",
+			rules: []Rule{
+				testRule("terminal", "Terminal", MatchExact, 1),
+				testRule("safari", "Safari", MatchExact, 1),
+				withWindow(testRule("docs", "Code", MatchExact, 1), "README"),
+				disabled,
+			},
+			available: []string{"com.test.terminal", "com.test.safari", "com.test.docs"},
+		},
+		{
+			name:  "available inputs unknown",
+			rules: []Rule{testRule("terminal", "Terminal", MatchExact, 1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := newTestMatcher(t, tt.rules)
+			result, err := ms.LintConfig(tt.available)
+			if err != nil {
+				t.Fatalf("LintConfig: %v", err)
+			}
+			if tt.kind == "" {
+				if len(result.Issues) != 0 {
+					t.Errorf("Issues = %v, want none", result.Issues)
+				}
+				return
+			}
+			if len(result.Issues) != 1 {
+				t.Fatalf("Issues = %v, want one %s issue", result.Issues, tt.kind)
+			}
+			issue := result.Issues[0]
+			if issue.Kind != tt.kind || issue.Path != tt.path || issue.Related != tt.related {
+				t.Errorf("issue = %+v, want %s at %s related to %q", issue, tt.kind, tt.path, tt.related)
+			}
+			if issue.Severity != SeverityWarning || issue.Message == "" {
+				t.Errorf("issue = %+v, want a warning with a message", issue)
+			}
+		})
+	}
+}
+
+func TestLintPolicyPrefixShadowsExact(t *testing.T) {
+	systemDir := t.TempDir()
+	policy := "rules:\n  - app: Term\n    appMatch: prefix\n    input: com.apple.keylayout.ABC\n    enabled: true\n"
+	if err := ioutil.WriteFile(filepath.Join(systemDir, "policy.yaml"), []byte(policy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ms, err := loadTestConfig(t, map[string]interface{}{
+		"version": currentConfigVersion,
+		"rules":   []Rule{testRule("terminal", "Terminal", MatchExact, 1)},
+	})
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	ms.SetSystemConfigDir(systemDir)
+	if err := ms.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig with policy: %v", err)
+	}
+
+	// 管理策略中的规则总是优先匹配，前缀规则也能遮蔽精确规则
+	result, err := ms.LintConfig(nil)
+	if err != nil {
+		t.Fatalf("LintConfig: %v", err)
+	}
+	if len(result.Issues) == 0 {
+		t.Fatal("no issues, want rules[0] shadowed by the policy rule")
+	}
+	issue := result.Issues[0]
+	if issue.Kind != LintShadowed || issue.Path != "rules[0]" || issue.RuleID != "terminal" || issue.Related != "policy:rules[0]" {
+		t.Errorf("issue = %+v, want rules[0] shadowed by policy:rules[0]", issue)
+	}
+}
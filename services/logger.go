@@ -28,6 +28,7 @@ type LogEntry struct {
 	Input     string    `json:"input,omitempty"`
 	Action    string    `json:"action,omitempty"`
 	Error     string    `json:"error,omitempty"`
+	Trace     *MatchExplanation `json:"trace,omitempty"` // 规则匹配过程（可选）
 }
 
 // LoggerService 日志服务
@@ -156,15 +157,44 @@ func (ls *LoggerService) LogInputSwitch(appName, inputId, action string, err err
 
 // LogRuleMatch 记录规则匹配日志
 func (ls *LoggerService) LogRuleMatch(appName, inputId string) {
+	ls.LogRuleMatchTrace(appName, inputId, nil)
+}
+
+// LogRuleMatchTrace 记录规则匹配日志，并附带匹配过程
+func (ls *LoggerService) LogRuleMatchTrace(appName, inputId string, trace *MatchExplanation) {
+	if !ls.enableLogging {
+		return
+	}
+	ls.write(LogEntry{
+		Timestamp: time.Now(),
+		Level:     ls.levelToString(LogLevelInfo),
+		Message:   fmt.Sprintf("规则匹配: %s -> %s", appName, inputId),
+		AppName:   appName,
+		Input:     inputId,
+		Action:    "rule_match",
+		Trace:     trace,
+	})
+}
+
+// LogInputSelect 记录从候选列表中选择输入法的日志
+func (ls *LoggerService) LogInputSelect(appName, inputId, reason string) {
+	if !ls.enableLogging {
+		return
+	}
+	ls.log(LogLevelInfo, fmt.Sprintf("输入法选择: %s -> %s (%s)", appName, inputId, reason), appName, inputId, "input_select", "")
+}
+
+// LogFallback 记录没有规则匹配时的兜底行为日志
+func (ls *LoggerService) LogFallback(appName, mode, inputId, detail string) {
 	if !ls.enableLogging {
 		return
 	}
-	ls.log(LogLevelInfo, fmt.Sprintf("规则匹配: %s -> %s", appName, inputId), appName, inputId, "rule_match", "")
+	ls.log(LogLevelInfo, fmt.Sprintf("没有匹配的规则: %s，兜底行为 %s (%s)", appName, mode, detail), appName, inputId, "fallback", "")
 }
 
 // log 内部日志记录方法
 func (ls *LoggerService) log(level LogLevel, message, appName, input, action, errorMsg string) {
-	entry := LogEntry{
+	ls.write(LogEntry{
 		Timestamp: time.Now(),
 		Level:     ls.levelToString(level),
 		Message:   message,
@@ -172,8 +202,11 @@ func (ls *LoggerService) log(level LogLevel, message, appName, input, action, er
 		Input:     input,
 		Action:    action,
 		Error:     errorMsg,
-	}
+	})
+}
 
+// write 将日志条目写入缓冲区
+func (ls *LoggerService) write(entry LogEntry) {
 	ls.bufferMutex.Lock()
 	ls.logBuffer = append(ls.logBuffer, entry)
 
@@ -1,29 +1,98 @@
 package services
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Rule 输入法切换规则
 type Rule struct {
+	ID         string `json:"id"`         // 规则唯一标识（自动生成）
 	AppName    string `json:"app"`        // 应用程序名称或包名
-	WindowName string `json:"window"`     // 窗口名称模式（可选）
-	Input      string `json:"input"`      // 目标输入法ID
+	WindowName string `json:"window,omitempty"` // 窗口名称模式（可选）
+	Input      InputList `json:"input"`   // 目标输入法ID（支持按顺序排列的备选列表）
 	Enabled    bool   `json:"enabled"`    // 是否启用
-	Priority   int    `json:"priority"`   // 优先级（数字越小优先级越高）
+	Priority   int    `json:"priority,omitempty"` // 优先级（数字越小优先级越高）
+	AppMatch    string `json:"appMatch,omitempty"`    // 应用名称匹配模式：exact/prefix/glob/regex/contains，留空时先精确匹配再模糊匹配
+	WindowMatch string `json:"windowMatch,omitempty"` // 窗口名称匹配模式，留空时为 contains（包含 * 时按 glob 处理）
+	Schedule    *Schedule `json:"schedule,omitempty"` // 生效时间（可选）
+	Exclude     *RuleExclude `json:"exclude,omitempty"` // 排除条件（可选）
+	Breaker    *RuleBreakerState `json:"breaker,omitempty"` // 熔断状态（运行时信息，不写入配置文件）
+	Source     string `json:"source,omitempty"`     // 规则来源：rules.d 中的文件名，管理策略中的规则为 policy，主配置文件中的规则为空（运行时信息，不写入配置文件）
+	Locked     bool   `json:"locked,omitempty"`     // 是否被管理策略锁定，锁定的规则不能修改或删除（运行时信息，不写入配置文件）
+}
+
+// RuleExclude 规则的排除条件，任意一项命中时规则不生效
+type RuleExclude struct {
+	App     []string `json:"app,omitempty"`     // 应用名称
+	Window  []string `json:"window,omitempty"`  // 窗口标题
+	AppPath []string `json:"appPath,omitempty"` // 应用路径
+	Match   string   `json:"match,omitempty"`   // 匹配模式，留空时为 contains（包含通配符时按 glob 处理）
+}
+
+// InputList 按优先顺序排列的目标输入法列表
+// 配置中既可以写成单个字符串，也可以写成字符串数组
+type InputList []string
+
+// UnmarshalJSON 同时支持字符串和字符串数组两种写法
+func (il *InputList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		if single == "" {
+			*il = nil
+		} else {
+			*il = InputList{single}
+		}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("input must be a string or an array of strings")
+	}
+	*il = InputList(list)
+	return nil
+}
+
+// MarshalJSON 只有一个输入法时写回字符串，保持配置文件简洁
+func (il InputList) MarshalJSON() ([]byte, error) {
+	if len(il) == 1 {
+		return json.Marshal(il[0])
+	}
+	if il == nil {
+		return json.Marshal("")
+	}
+	return json.Marshal([]string(il))
+}
+
+// String 返回便于日志显示的文本
+func (il InputList) String() string {
+	return strings.Join(il, " | ")
 }
 
 // Config 配置文件结构
 type Config struct {
-	Rules         []Rule       `json:"rules"`         // 切换规则
+	Version       int          `json:"version"`       // 配置文件格式版本
+	Inputs        map[string]InputList `json:"inputs,omitempty"` // 输入法别名 -> 输入法ID或备选列表
+	Rules         []Rule       `json:"rules"`         // 切换规则（所有配置方案共享）
+	Profiles      map[string]Profile `json:"profiles,omitempty"` // 配置方案
+	ActiveProfile string       `json:"activeProfile,omitempty"` // 手动选择的配置方案，留空时按自动启用条件选择
 	General       GeneralConfig `json:"general"`       // 通用配置
-	LastModified  string       `json:"lastModified"`  // 最后修改时间
+	LastModified  string       `json:"lastModified"`  // 最后修改时间（RFC 3339）
+	Revision      string       `json:"revision,omitempty"` // 配置文件内容的版本，保存时用于检测冲突（运行时信息，不写入配置文件）
+	Includes      []RuleSource `json:"-"`             // 管理策略和 rules.d 目录中的只读规则，加载配置时读取，不写入配置文件
+	ValueSources  map[string]string `json:"valueSources,omitempty"` // 各配置项的值来自哪一层，键为配置项位置，例如 general.logLevel -> user（运行时信息，不写入配置文件）
+	Locked        []string     `json:"locked,omitempty"` // 被管理策略锁定的配置项位置（运行时信息，不写入配置文件）
 }
 
 // GeneralConfig 通用配置
@@ -34,27 +103,74 @@ type GeneralConfig struct {
 	EnableLogging   bool          `json:"enableLogging"`   // 启用日志
 	LogLevel        string        `json:"logLevel"`        // 日志级别
 	ShowNotifications bool       `json:"showNotifications"` // 显示通知
+	BreakerThreshold  int        `json:"breakerThreshold"`  // 规则连续失败多少次后暂停
+	BreakerBackoff    int        `json:"breakerBackoff"`    // 规则暂停后首次重试的等待时间（毫秒），之后按指数增长
+	LogMatchTrace     bool       `json:"logMatchTrace"`     // 在规则匹配日志中附带匹配过程
+	IgnoreApps        []string   `json:"ignoreApps,omitempty"` // 从不触发切换的应用（如启动器、截图工具、通知弹窗），支持通配符
+	Fallback          FallbackConfig `json:"fallback"`      // 没有规则匹配时的处理方式
+	BackupCount       int        `json:"backupCount"`       // 保存配置时保留的历史备份数量
+}
+
+// 没有规则匹配时的处理方式
+const (
+	FallbackNone    = "none"    // 保持当前输入法不变
+	FallbackDefault = "default" // 切换到默认输入法
+	FallbackRestore = "restore" // 恢复该应用上次使用的输入法
+)
+
+// FallbackConfig 没有规则匹配时的处理配置
+type FallbackConfig struct {
+	Mode  string    `json:"mode"`            // none / default / restore
+	Input InputList `json:"input,omitempty"` // mode 为 default 时切换到的输入法，支持别名和备选列表
 }
 
+// maxBreakerBackoff 规则暂停后重试等待时间的上限
+const maxBreakerBackoff = time.Hour
+
 // MatcherService 规则匹配服务
 type MatcherService struct {
-	config     *Config
+	snapshot   atomic.Pointer[configSnapshot] // 当前配置快照，读取时不需要加锁
 	configPath string
-	ruleMap    map[string][]Rule // appName -> rules
-	ruleMutex  sync.RWMutex
+	format     string            // 配置文件格式，由扩展名决定
+	hostname   string            // 本机主机名，用于自动启用配置方案
+	ruleMutex  sync.RWMutex      // 串行化配置的加载和修改，保护下面的设置项
 	onRuleMatch func(*Rule, *WindowInfo)
+	breaker    *CircuitBreaker
+	now        atomic.Value     // 时钟 func() time.Time，用于评估规则的生效时间
+	onReload   func(error)      // 配置文件变化后自动重新加载的回调
+	onNotice   func(level LogLevel, message string) // 加载配置过程中需要记录到日志的提示
+	watchStop  chan bool
+	systemConfigDir string                 // 系统配置文件所在目录，为空表示不使用
+	envLayer        map[string]interface{} // 环境变量层
+	flagLayer       map[string]interface{} // 命令行参数层
 }
 
 // NewMatcherService 创建新的规则匹配服务
 func NewMatcherService(configPath string) *MatcherService {
-	return &MatcherService{
+	ms := &MatcherService{
 		configPath: configPath,
-		ruleMap:    make(map[string][]Rule),
+		format:     configFormat(configPath),
+		breaker:    NewCircuitBreaker(3, time.Minute, maxBreakerBackoff),
+		hostname:   localHostname(),
+		watchStop:  make(chan bool),
+		systemConfigDir: DefaultSystemConfigDir,
 	}
+	ms.now.Store(time.Now)
+	return ms
 }
 
-// LoadConfig 加载配置文件
+// SetClock 设置评估规则生效时间使用的时钟
+func (ms *MatcherService) SetClock(now func() time.Time) {
+	ms.now.Store(now)
+}
+
+// LoadConfig 加载配置文件，没有ID的规则分配ID后写回文件
 func (ms *MatcherService) LoadConfig() error {
+	return ms.loadConfig(true)
+}
+
+// loadConfig 加载配置文件，writeIDs 为 false 时新分配的规则ID只保存在内存中（自动重新加载时使用）
+func (ms *MatcherService) loadConfig(writeIDs bool) error {
 	ms.ruleMutex.Lock()
 	defer ms.ruleMutex.Unlock()
 
@@ -71,12 +187,69 @@ func (ms *MatcherService) LoadConfig() error {
 		return fmt.Errorf("failed to read config file: %v", err)
 	}
 
+	sources, err := loadRuleSources(ms.ruleSourceDir())
+	if err != nil {
+		return err
+	}
+
+	config, compiled, version, err := ms.loadConfigDataLocked(data, sources)
+	if err != nil {
+		return err
+	}
+
+	if version < currentConfigVersion {
+		// 升级前备份原始文件，之后以当前版本的格式写回
+		backupPath, err := ms.backupBeforeMigration(data, version)
+		if err != nil {
+			return fmt.Errorf("failed to back up config before migration: %v", err)
+		}
+		ms.noticeLocked(LogLevelInfo, fmt.Sprintf("配置文件已从版本 %d 升级到版本 %d，原文件备份在 %s", version, currentConfigVersion, backupPath))
+		return ms.useConfigLocked(config, compiled, data, true, writeIDs)
+	}
+
+	return ms.useConfigLocked(config, compiled, data, false, writeIDs)
+}
+
+// loadConfigDataLocked 解析用户配置文件内容：转换为 JSON，将旧版本升级到当前版本，
+// 与其他各层以及 rules.d 中的规则文件 sources 合并后校验，同时返回原始版本，调用方需持有锁
+func (ms *MatcherService) loadConfigDataLocked(data []byte, sources []RuleSource) (*Config, *compiledConfig, int, error) {
+	converted, err := decodeConfigData(data, ms.format)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	migrated, version, err := migrateConfigData(converted)
+	if err != nil {
+		return nil, nil, version, err
+	}
+
+	config, compiled, err := ms.buildConfigLocked(migrated, sources)
+	if err != nil {
+		return nil, nil, version, err
+	}
+	config.Revision = configRevision(data)
+	return config, compiled, version, nil
+}
+
+// parseConfig 解析 JSON 格式的配置内容，设置默认值，合并规则文件后校验
+func parseConfig(data []byte, sources []RuleSource) (*Config, *compiledConfig, error) {
 	var config Config
 	if err := json.Unmarshal(data, &config); err != nil {
-		return fmt.Errorf("failed to parse config: %v", err)
+		return nil, nil, fmt.Errorf("failed to parse config: %s", describeJSONError(data, err))
+	}
+	applyConfigDefaults(&config)
+	config.Includes = sources
+
+	compiled, err := compileConfig(&config)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// 设置默认值
+	return &config, compiled, nil
+}
+
+// applyConfigDefaults 为未设置的通用配置项设置默认值
+func applyConfigDefaults(config *Config) {
 	if config.General.CheckInterval == 0 {
 		config.General.CheckInterval = 500
 	}
@@ -86,38 +259,294 @@ func (ms *MatcherService) LoadConfig() error {
 	if config.General.LogLevel == "" {
 		config.General.LogLevel = "info"
 	}
+	if config.General.BreakerThreshold == 0 {
+		config.General.BreakerThreshold = 3
+	}
+	if config.General.BreakerBackoff == 0 {
+		config.General.BreakerBackoff = 60000
+	}
+	if config.General.Fallback.Mode == "" {
+		config.General.Fallback.Mode = FallbackNone
+	}
+	if config.General.BackupCount == 0 {
+		config.General.BackupCount = defaultBackupCount
+	}
+}
+
+// useConfigLocked 使用已校验的配置替换当前配置，调用方需持有写锁
+// data 为配置文件的内容；migrated 表示配置刚完成版本升级，需要以当前版本的格式写回文件；
+// writeIDs 表示需要把新分配的规则ID写回文件。写回失败时记录日志，继续使用内存中的配置
+func (ms *MatcherService) useConfigLocked(config *Config, compiled *compiledConfig, data []byte, migrated, writeIDs bool) error {
+	// 旧配置中没有ID的规则在加载时分配ID
+	assigned := assignRuleIDs(config)
+	if migrated || (assigned && writeIDs) {
+		err := ms.saveConfigLocked(config.Clone())
+		if err == nil {
+			return nil
+		}
+		ms.noticeLocked(LogLevelWarn, fmt.Sprintf("无法写回配置文件，新分配的规则ID只保存在内存中，下次保存配置时写入: %v", err))
+	}
+	if assigned {
+		var err error
+		if compiled, err = compileConfig(config); err != nil {
+			return err
+		}
+	}
+	ms.publishLocked(config, compiled, data)
+	return nil
+}
+
+// newRuleID 生成新的规则ID
+func newRuleID() string {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		// 随机数不可用时退回到时间戳
+		return fmt.Sprintf("%012x", time.Now().UnixNano()&0xffffffffffff)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// assignRuleIDs 为没有ID或ID重复的规则分配ID，返回是否有规则被修改
+// rules.d 中的规则不能写回，只为主配置文件中的规则分配，与规则文件重复的ID也会重新分配
+func assignRuleIDs(config *Config) bool {
+	changed := false
+	seen := make(map[string]bool)
+	refs := config.ruleRefs()
+	for _, ref := range refs {
+		if ref.source != "" {
+			seen[config.rule(ref).ID] = true
+		}
+	}
+	for _, ref := range refs {
+		if ref.source != "" {
+			continue
+		}
+		rule := config.rule(ref)
+		id := rule.ID
+		if id == "" || seen[id] {
+			id = generatedRuleID(*rule, 0)
+			for n := 1; seen[id]; n++ {
+				id = generatedRuleID(*rule, n)
+			}
+			rule.ID = id
+			changed = true
+		}
+		seen[id] = true
+	}
+	return changed
+}
+
+// generatedRuleID 根据规则内容为加载时没有ID的规则生成ID，n 用于避开已使用的ID
+// 同样的规则总是得到同样的ID，ID 尚未写回文件时重新加载配置也不会改变；新添加的规则使用 newRuleID
+func generatedRuleID(rule Rule, n int) string {
+	rule.ID = ""
+	rule.Breaker = nil
+	rule.Source = ""
+	rule.Locked = false
+	data, _ := json.Marshal(rule)
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s#%d", data, n)))
+	return hex.EncodeToString(sum[:6])
+}
+
+// isInputAlias 判断输入法条目是否为别名：只有在 inputs 中定义过的名称才是别名，其余条目都视为输入法ID
+func isInputAlias(config *Config, entry string) bool {
+	_, exists := config.Inputs[entry]
+	return exists
+}
 
-	ms.config = &config
-	ms.buildRuleMap()
+// inputErrors 校验别名定义以及规则和兜底行为中引用的输入法
+func inputErrors(config *Config) []error {
+	var errs []error
 
+	aliases := make([]string, 0, len(config.Inputs))
+	for alias := range config.Inputs {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	for _, alias := range aliases {
+		inputs := config.Inputs[alias]
+		path := "inputs." + alias
+		if alias == "" || strings.Contains(alias, ".") {
+			errs = append(errs, configErrorf(path, "alias name must not be empty or contain '.'"))
+			continue
+		}
+		if len(inputs) == 0 {
+			errs = append(errs, configErrorf(path, "alias has no input IDs"))
+			continue
+		}
+		for j, inputID := range inputs {
+			if strings.TrimSpace(inputID) == "" {
+				errs = append(errs, configErrorf(fmt.Sprintf("%s[%d]", path, j), "empty input ID"))
+				continue
+			}
+			if isInputAlias(config, inputID) {
+				errs = append(errs, configErrorf(path, "%q must be an input ID, aliases cannot refer to other aliases", inputID))
+			}
+		}
+	}
+
+	for _, ref := range config.ruleRefs() {
+		errs = append(errs, inputListErrors(config, ref.location+".input", config.rule(ref).Input, true)...)
+	}
+	errs = append(errs, inputListErrors(config, "general.fallback.input", config.General.Fallback.Input, false)...)
+
+	return errs
+}
+
+// inputListErrors 校验输入法列表中的条目：不能为空，不带 . 的条目必须是定义过的别名
+// 输入法ID都是 com.apple.keylayout.ABC 这样带点的形式，不带点又未定义的条目多半是写错的别名
+func inputListErrors(config *Config, path string, inputs InputList, required bool) []error {
+	if required && len(inputs) == 0 {
+		return []error{configErrorf(path, "at least one input is required")}
+	}
+
+	var errs []error
+	for j, entry := range inputs {
+		if strings.TrimSpace(entry) == "" {
+			errs = append(errs, configErrorf(fmt.Sprintf("%s[%d]", path, j), "empty input ID"))
+			continue
+		}
+		if !isInputAlias(config, entry) && !strings.Contains(entry, ".") {
+			errs = append(errs, configErrorf(path, "unknown input alias %q", entry))
+		}
+	}
+	return errs
+}
+
+// validateFallback 校验兜底行为配置
+func validateFallback(fallback *FallbackConfig) error {
+	switch fallback.Mode {
+	case "", FallbackNone, FallbackRestore:
+	case FallbackDefault:
+		if len(fallback.Input) == 0 {
+			return configErrorf("general.fallback.input", "required when mode is %q", FallbackDefault)
+		}
+	default:
+		return configErrorf("general.fallback.mode", "unknown mode %q (expected none, default or restore)", fallback.Mode)
+	}
 	return nil
 }
 
+// logLevels 支持的日志级别
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+// generalErrors 校验通用配置中的枚举值和数值范围
+func generalErrors(general *GeneralConfig) []error {
+	var errs []error
+
+	validLevel := false
+	for _, level := range logLevels {
+		if general.LogLevel == level {
+			validLevel = true
+		}
+	}
+	if !validLevel {
+		errs = append(errs, configErrorf("general.logLevel", "unknown log level %q (expected %s)", general.LogLevel, strings.Join(logLevels, ", ")))
+	}
+
+	values := []struct {
+		name  string
+		value int
+	}{
+		{"checkInterval", general.CheckInterval},
+		{"switchDelay", general.SwitchDelay},
+		{"breakerThreshold", general.BreakerThreshold},
+		{"breakerBackoff", general.BreakerBackoff},
+		{"backupCount", general.BackupCount},
+	}
+	for _, v := range values {
+		if v.value < 0 {
+			errs = append(errs, configErrorf("general."+v.name, "must not be negative, got %d", v.value))
+		}
+	}
+
+	return errs
+}
+
+// ResolveInputs 将规则中的别名展开为具体的输入法ID列表，保持原有顺序并去重
+func (ms *MatcherService) ResolveInputs(inputs InputList) InputList {
+	var config *Config
+	if snapshot := ms.current(); snapshot != nil {
+		config = snapshot.config
+	}
+	return config.resolveInputs(inputs)
+}
+
+// resolveInputs 按配置中的别名展开输入法列表，config 为 nil 时只去重
+func (c *Config) resolveInputs(inputs InputList) InputList {
+	var resolved InputList
+	seen := make(map[string]bool)
+	for _, entry := range inputs {
+		expanded := InputList{entry}
+		if c != nil {
+			if aliasInputs, exists := c.Inputs[entry]; exists {
+				expanded = aliasInputs
+			}
+		}
+		for _, inputID := range expanded {
+			if !seen[inputID] {
+				seen[inputID] = true
+				resolved = append(resolved, inputID)
+			}
+		}
+	}
+
+	return resolved
+}
+
+// InputLabel 返回输入法ID在规则中对应的显示名称
+// 如果该ID来自某个别名则返回别名，否则返回ID本身
+func (ms *MatcherService) InputLabel(inputs InputList, inputID string) string {
+	snapshot := ms.current()
+	if snapshot == nil {
+		return inputID
+	}
+
+	for _, entry := range inputs {
+		if entry == inputID {
+			return inputID
+		}
+		for _, aliasInput := range snapshot.config.Inputs[entry] {
+			if aliasInput == inputID {
+				return entry
+			}
+		}
+	}
+
+	return inputID
+}
+
 // createDefaultConfig 创建默认配置文件
 func (ms *MatcherService) createDefaultConfig() error {
 	defaultConfig := &Config{
+		Version: currentConfigVersion,
+		Inputs: map[string]InputList{
+			"zh": {"com.tencent.inputmethod.wetype.pinyin"},
+			"en": {"com.apple.keylayout.ABC"},
+		},
 		Rules: []Rule{
 			{
 				AppName:  "com.apple.Safari",
-				Input:    "com.tencent.inputmethod.wetype.pinyin",
+				Input:    InputList{"zh"},
 				Enabled:  true,
 				Priority: 1,
 			},
 			{
 				AppName:  "com.google.Chrome",
-				Input:    "com.tencent.inputmethod.wetype.pinyin",
+				Input:    InputList{"zh"},
 				Enabled:  true,
 				Priority: 1,
 			},
 			{
 				AppName:  "com.apple.Terminal",
-				Input:    "com.apple.keylayout.ABC",
+				Input:    InputList{"en"},
 				Enabled:  true,
 				Priority: 1,
 			},
 			{
 				AppName:  "com.microsoft.VSCode",
-				Input:    "com.apple.keylayout.ABC",
+				Input:    InputList{"en"},
 				Enabled:  true,
 				Priority: 1,
 			},
@@ -129,219 +558,694 @@ func (ms *MatcherService) createDefaultConfig() error {
 			EnableLogging:    true,
 			LogLevel:         "info",
 			ShowNotifications: true,
+			BreakerThreshold:  3,
+			BreakerBackoff:    60000,
+			Fallback:          FallbackConfig{Mode: FallbackNone},
+			BackupCount:       defaultBackupCount,
 		},
 	}
 
-	return ms.SaveConfig(defaultConfig)
+	return ms.saveConfigLocked(defaultConfig)
 }
 
 // SaveConfig 保存配置文件
+// rules.d 和管理策略中的规则是只读的，config.Includes 会被替换为当前加载的规则文件；
+// 修改被管理策略锁定的配置项时返回 ErrLocked。
+// config.Revision 为调用方基于的版本（GetConfig 返回的配置已带有），配置文件在此之后被修改过时返回 *ConflictError
 func (ms *MatcherService) SaveConfig(config *Config) error {
 	ms.ruleMutex.Lock()
 	defer ms.ruleMutex.Unlock()
 
-	// 确保目录存在
-	dir := filepath.Dir(ms.configPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create config directory: %v", err)
+	// 保存调用方配置的副本，之后调用方修改 config 不会影响当前配置
+	config = config.Clone()
+	config.Includes = nil
+	if snapshot := ms.current(); snapshot != nil {
+		config.Includes = snapshot.config.Includes
 	}
+	return ms.saveConfigLocked(config)
+}
 
-	// 更新最后修改时间
-	config.LastModified = fmt.Sprintf("%d", os.Getpid())
+// saveConfigLocked 校验并保存配置文件，调用方需持有写锁
+func (ms *MatcherService) saveConfigLocked(config *Config) error {
+	assignRuleIDs(config)
 
-	// 序列化配置
+	compiled, err := compileConfig(config)
+	if err != nil {
+		return err
+	}
+
+	// 更新版本和最后修改时间
+	base := config.Revision
+	config.Version = currentConfigVersion
+	config.LastModified = ms.currentTime().Format(time.RFC3339)
+
+	// 熔断状态、规则来源和锁定状态只在运行时有效，不写入配置文件
+	config.copyRules()
+	for _, ref := range config.ruleRefs() {
+		rule := config.rule(ref)
+		rule.Breaker = nil
+		if ref.source == "" {
+			rule.Source = ""
+			rule.Locked = false
+		}
+	}
+	submitted := *config
+	config.Locked = nil
+	config.Revision = ""
+	config.ValueSources = nil
+
+	// 序列化配置，只保留属于用户配置文件的部分
 	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %v", err)
 	}
+	original, readErr := ioutil.ReadFile(ms.configPath)
+	// 文件不存在时 originalLayer 为 nil，新文件写入所有默认值
+	var originalLayer map[string]interface{}
+	if converted, err := decodeConfigData(original, ms.format); readErr == nil && err == nil {
+		json.Unmarshal(converted, &originalLayer)
+	}
+	userData, err := ms.userLayerDataLocked(data, originalLayer)
+	if err != nil {
+		return err
+	}
+
+	// 重新与其他各层合并，得到与重新加载后相同的配置
+	config, compiled, err = ms.buildConfigLocked(userData, config.Includes)
+	if err != nil {
+		return err
+	}
+
+	// 锁定的配置项由管理策略决定，修改会在合并时被覆盖，因此拒绝保存
+	if changed := lockedChanges(config, &submitted); len(changed) > 0 {
+		return lockedError(changed)
+	}
+
+	// 按配置文件原有的格式写回
+	if data, err = encodeConfigData(userData, original, ms.format); err != nil {
+		return err
+	}
+
+	// 配置文件在调用方读取之后被修改过（例如在编辑器中保存），拒绝覆盖
+	if revision := fileRevision(original, readErr); revision != base {
+		return ms.conflictError(base, revision, data, original)
+	}
+	config.Revision = configRevision(data)
 
 	// 写入文件
-	if err := ioutil.WriteFile(ms.configPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write config file: %v", err)
+	if err := ms.writeConfigFileLocked(data, config.General.BackupCount); err != nil {
+		return err
 	}
 
-	ms.config = config
-	ms.buildRuleMap()
+	ms.publishLocked(config, compiled, data)
 
 	return nil
 }
 
-// buildRuleMap 构建规则映射表
-func (ms *MatcherService) buildRuleMap() {
-	ms.ruleMap = make(map[string][]Rule)
+// compiledRule 预编译的规则
+type compiledRule struct {
+	rule   Rule
+	ref    ruleRef    // 规则在配置中的位置
+	index  int        // 规则在所有规则中的顺序
+	apps   []*pattern // 应用名称模式
+	fuzzy  bool       // 未声明匹配模式时，精确匹配失败后允许模糊匹配
+	window *pattern   // 窗口名称模式，为 nil 表示匹配所有窗口
+	schedule *compiledSchedule // 生效时间，为 nil 表示始终生效
+	excludes []excludeCondition // 排除条件
+}
+
+// excludeCondition 编译后的排除条件
+type excludeCondition struct {
+	field   string // app / window / appPath
+	pattern *pattern
+}
 
-	if ms.config == nil {
-		return
+// value 返回窗口中排除条件对应字段的值
+func (ec excludeCondition) value(window *WindowInfo) string {
+	switch ec.field {
+	case "app":
+		return window.AppName
+	case "window":
+		return window.WindowName
+	default:
+		return window.AppPath
 	}
+}
 
-	for _, rule := range ms.config.Rules {
+// compileRules 编译配置中所有启用的规则，并按优先级排序
+// 模式无效时返回带规则位置的错误
+func compileRules(config *Config) ([]compiledRule, []error) {
+	var compiled []compiledRule
+	var errs []error
+
+	for i, ref := range config.ruleRefs() {
+		rule := *config.rule(ref)
 		if !rule.Enabled {
 			continue
 		}
 
-		// 支持多个应用名称，用逗号分隔
-		appNames := strings.Split(rule.AppName, ",")
-		for _, appName := range appNames {
-			appName = strings.TrimSpace(appName)
-			if appName != "" {
-				ms.ruleMap[appName] = append(ms.ruleMap[appName], rule)
-			}
+		cr, ruleErrs := compileRule(rule, ref, i)
+		if len(ruleErrs) > 0 {
+			errs = append(errs, ruleErrs...)
+			continue
 		}
+		compiled = append(compiled, cr)
 	}
 
-	// 对每个应用的规则按优先级排序
-	for appName := range ms.ruleMap {
-		rules := ms.ruleMap[appName]
-		for i := 0; i < len(rules)-1; i++ {
-			for j := i + 1; j < len(rules); j++ {
-				if rules[i].Priority > rules[j].Priority {
-					rules[i], rules[j] = rules[j], rules[i]
-				}
-			}
-		}
-	}
+	// 按优先级排序，优先级相同时保持配置中的顺序
+	sort.SliceStable(compiled, func(i, j int) bool {
+		return compiled[i].rule.Priority < compiled[j].rule.Priority
+	})
+
+	return compiled, errs
 }
 
-// MatchWindow 匹配窗口并返回对应的规则
-func (ms *MatcherService) MatchWindow(window *WindowInfo) *Rule {
-	ms.ruleMutex.RLock()
-	defer ms.ruleMutex.RUnlock()
+// compileRule 编译单条规则，返回规则中所有无效的模式
+func compileRule(rule Rule, ref ruleRef, index int) (compiledRule, []error) {
+	cr := compiledRule{rule: rule, ref: ref, index: index}
+	var errs []error
 
-	if window == nil {
-		return nil
+	appMode := rule.AppMatch
+	if appMode == "" {
+		appMode = MatchExact
+		cr.fuzzy = true
+	}
+
+	// 支持多个应用名称，用逗号分隔；正则模式下逗号可能是表达式的一部分，不做拆分
+	appNames := []string{rule.AppName}
+	if appMode != MatchRegex {
+		appNames = strings.Split(rule.AppName, ",")
+	}
+	for _, appName := range appNames {
+		if strings.TrimSpace(appName) == "" {
+			continue
+		}
+		p, err := compilePattern(appMode, appName)
+		if err != nil {
+			errs = append(errs, configErrorf(ref.location+".app", "%v", err))
+			break
+		}
+		cr.apps = append(cr.apps, p)
 	}
 
-	// 首先尝试精确匹配应用名称
-	if rules, exists := ms.ruleMap[window.AppName]; exists {
-		for _, rule := range rules {
-			if ms.windowNameMatches(window.WindowName, rule.WindowName) {
-				return &rule
+	if strings.TrimSpace(rule.WindowName) != "" {
+		windowMode := rule.WindowMatch
+		if windowMode == "" {
+			windowMode = MatchContains
+			if strings.Contains(rule.WindowName, "*") {
+				windowMode = MatchGlob
 			}
 		}
+		p, err := compilePattern(windowMode, rule.WindowName)
+		if err != nil {
+			errs = append(errs, configErrorf(ref.location+".window", "%v", err))
+		}
+		cr.window = p
+	} else if rule.WindowMatch != "" {
+		if _, err := compilePattern(rule.WindowMatch, ""); err != nil {
+			errs = append(errs, configErrorf(ref.location+".windowMatch", "%v", err))
+		}
+	}
+
+	if rule.Schedule != nil {
+		schedule, err := compileSchedule(rule.Schedule)
+		if err != nil {
+			errs = append(errs, wrapConfigError(ref.location+".schedule", err))
+		}
+		cr.schedule = schedule
 	}
 
-	// 尝试模糊匹配
-	for appName, rules := range ms.ruleMap {
-		if ms.appNameMatches(window.AppName, appName) {
-			for _, rule := range rules {
-				if ms.windowNameMatches(window.WindowName, rule.WindowName) {
-					return &rule
+	if rule.Exclude != nil {
+		fields := []struct {
+			name   string
+			values []string
+		}{
+			{"app", rule.Exclude.App},
+			{"window", rule.Exclude.Window},
+			{"appPath", rule.Exclude.AppPath},
+		}
+		for _, field := range fields {
+			for j, value := range field.values {
+				mode := rule.Exclude.Match
+				if mode == "" {
+					mode = defaultPatternMode(value, MatchContains)
+				}
+				p, err := compilePattern(mode, value)
+				if err != nil {
+					errs = append(errs, configErrorf(fmt.Sprintf("%s.exclude.%s[%d]", ref.location, field.name, j), "%v", err))
+					continue
 				}
+				cr.excludes = append(cr.excludes, excludeCondition{field: field.name, pattern: p})
 			}
 		}
 	}
 
-	return nil
+	return cr, errs
 }
 
-// appNameMatches 应用名称匹配
-func (ms *MatcherService) appNameMatches(currentAppName, ruleAppName string) bool {
-	currentAppName = strings.ToLower(strings.TrimSpace(currentAppName))
-	ruleAppName = strings.ToLower(strings.TrimSpace(ruleAppName))
+// compiledConfig 预编译的配置
+type compiledConfig struct {
+	rules    []compiledRule
+	ignore   []*pattern        // 全局忽略的应用
+	profiles []compiledProfile // 配置方案的自动启用条件
+}
 
-	// 精确匹配
-	if currentAppName == ruleAppName {
-		return true
+// compileConfig 校验并编译配置，返回第一个错误
+func compileConfig(config *Config) (*compiledConfig, error) {
+	cc, errs := compileConfigAll(config)
+	if len(errs) > 0 {
+		return nil, errs[0]
 	}
+	return cc, nil
+}
 
-	// 包含匹配
-	if strings.Contains(currentAppName, ruleAppName) || strings.Contains(ruleAppName, currentAppName) {
-		return true
+// compileConfigAll 校验并编译配置，收集所有错误
+func compileConfigAll(config *Config) (*compiledConfig, []error) {
+	errs := inputErrors(config)
+	if err := validateFallback(&config.General.Fallback); err != nil {
+		errs = append(errs, err)
 	}
+	errs = append(errs, generalErrors(&config.General)...)
 
-	// 包含关键词匹配
-	ruleParts := strings.Split(ruleAppName, " ")
-	for _, part := range ruleParts {
-		if strings.TrimSpace(part) != "" && strings.Contains(currentAppName, part) {
-			return true
+	profiles, profileErrs := compileProfiles(config)
+	errs = append(errs, profileErrs...)
+	errs = append(errs, ruleSourceErrors(config)...)
+
+	rules, ruleErrs := compileRules(config)
+	errs = append(errs, ruleErrs...)
+
+	cc := &compiledConfig{rules: rules, profiles: profiles}
+	for i, appName := range config.General.IgnoreApps {
+		p, err := compilePattern(defaultPatternMode(appName, MatchExact), appName)
+		if err != nil {
+			errs = append(errs, configErrorf(fmt.Sprintf("general.ignoreApps[%d]", i), "%v", err))
+			continue
 		}
+		cc.ignore = append(cc.ignore, p)
+	}
+
+	return cc, errs
+}
+
+// defaultPatternMode 未声明匹配模式时使用的模式：包含通配符时按 glob，否则使用 fallback
+func defaultPatternMode(value, fallback string) string {
+	if strings.ContainsAny(value, "*?") {
+		return MatchGlob
 	}
+	return fallback
+}
 
+// isIgnored 判断窗口所属应用是否在快照的全局忽略列表中
+func (s *configSnapshot) isIgnored(window *WindowInfo) bool {
+	for _, p := range s.compiled.ignore {
+		if p.match(window.AppName) {
+			return true
+		}
+	}
 	return false
 }
 
-// windowNameMatches 窗口名称匹配
-func (ms *MatcherService) windowNameMatches(currentWindowName, ruleWindowName string) bool {
+// IsIgnored 判断窗口所属应用是否在全局忽略列表中，忽略的应用不会触发任何切换
+func (ms *MatcherService) IsIgnored(window *WindowInfo) bool {
+	snapshot := ms.current()
+	if window == nil || snapshot == nil {
+		return false
+	}
+	return snapshot.isIgnored(window)
+}
+
+// 匹配得分：应用名称的匹配方式决定得分档位，窗口条件在同一档位内增加特异性
+const (
+	scoreExact       = 500
+	scorePrefix      = 400
+	scoreGlob        = 300
+	scoreRegex       = 300
+	scoreContains    = 200
+	scoreFuzzy       = 100
+	scoreWindowMatch = 50
+)
+
+// MatchFuzzy 默认模式下精确匹配失败后的模糊匹配方式
+const MatchFuzzy = "fuzzy"
+
+// modeScore 返回匹配方式对应的得分
+func modeScore(mode string) int {
+	switch mode {
+	case MatchExact:
+		return scoreExact
+	case MatchPrefix:
+		return scorePrefix
+	case MatchGlob:
+		return scoreGlob
+	case MatchRegex:
+		return scoreRegex
+	case MatchContains:
+		return scoreContains
+	case MatchFuzzy:
+		return scoreFuzzy
+	default:
+		return 0
+	}
+}
+
+// ruleEvaluation 规则对某个窗口的评估结果
+type ruleEvaluation struct {
+	cr         *compiledRule
+	matched    bool
+	score      int
+	appMode    string // 应用名称实际命中的匹配方式
+	appPattern string // 命中的应用名称模式
+	conditions []ConditionResult
+}
+
+// appScore 计算应用名称的最佳匹配方式及得分，未匹配时得分为 0
+func (cr *compiledRule) appScore(appName string) (int, string, string) {
+	bestScore, bestMode, bestPattern := 0, "", ""
+	for _, p := range cr.apps {
+		mode := ""
+		if p.match(appName) {
+			mode = p.mode
+		} else if cr.fuzzy && fuzzyAppMatch(appName, p.raw) {
+			mode = MatchFuzzy
+		}
+		if score := modeScore(mode); score > bestScore {
+			bestScore, bestMode, bestPattern = score, mode, p.raw
+		}
+	}
+	return bestScore, bestMode, bestPattern
+}
+
+// windowMatches 判断窗口名称是否匹配规则
+func (cr *compiledRule) windowMatches(windowName string) bool {
 	// 如果规则中没有指定窗口名称，则匹配所有窗口
-	if ruleWindowName == "" {
+	if cr.window == nil {
 		return true
 	}
+	return cr.window.match(windowName)
+}
 
-	currentWindowName = strings.ToLower(strings.TrimSpace(currentWindowName))
-	ruleWindowName = strings.ToLower(strings.TrimSpace(ruleWindowName))
+// evaluate 评估规则在 now 时刻是否匹配窗口并计算得分，同时记录每个条件的评估结果
+func (cr *compiledRule) evaluate(window *WindowInfo, now time.Time) ruleEvaluation {
+	eval := ruleEvaluation{cr: cr}
 
-	// 支持通配符匹配
-	if strings.Contains(ruleWindowName, "*") {
-		pattern := strings.ReplaceAll(ruleWindowName, "*", ".*")
-		return strings.Contains(currentWindowName, strings.ReplaceAll(pattern, ".*", ""))
+	eval.score, eval.appMode, eval.appPattern = cr.appScore(window.AppName)
+	appCondition := ConditionResult{
+		Field:   "app",
+		Mode:    eval.appMode,
+		Pattern: eval.appPattern,
+		Value:   window.AppName,
+		Passed:  eval.score > 0,
+	}
+	if !appCondition.Passed {
+		appCondition.Mode = cr.rule.AppMatch
+		appCondition.Pattern = cr.rule.AppName
+		appCondition.Detail = "应用名称不匹配"
 	}
+	eval.conditions = append(eval.conditions, appCondition)
 
-	// 包含匹配
-	if strings.Contains(currentWindowName, ruleWindowName) {
-		return true
+	windowPassed := cr.windowMatches(window.WindowName)
+	if cr.window != nil {
+		windowCondition := ConditionResult{
+			Field:   "window",
+			Mode:    cr.window.mode,
+			Pattern: cr.window.raw,
+			Value:   window.WindowName,
+			Passed:  windowPassed,
+		}
+		if !windowPassed {
+			windowCondition.Detail = "窗口名称不匹配"
+		}
+		eval.conditions = append(eval.conditions, windowCondition)
 	}
 
-	return false
+	excludePassed := true
+	for _, exclude := range cr.excludes {
+		value := exclude.value(window)
+		excluded := exclude.pattern.match(value)
+		condition := ConditionResult{
+			Field:   "exclude." + exclude.field,
+			Mode:    exclude.pattern.mode,
+			Pattern: exclude.pattern.raw,
+			Value:   value,
+			Passed:  !excluded,
+		}
+		if excluded {
+			condition.Detail = "命中排除条件"
+			excludePassed = false
+		}
+		eval.conditions = append(eval.conditions, condition)
+	}
+
+	schedulePassed := true
+	if cr.schedule != nil {
+		var detail string
+		schedulePassed, detail = cr.schedule.active(now)
+		scheduleCondition := ConditionResult{
+			Field:  "schedule",
+			Value:  now.Format(time.RFC3339),
+			Passed: schedulePassed,
+		}
+		if !schedulePassed {
+			scheduleCondition.Detail = "不在生效时间内: " + detail
+		}
+		eval.conditions = append(eval.conditions, scheduleCondition)
+	}
+
+	if eval.score == 0 || !windowPassed || !excludePassed || !schedulePassed {
+		eval.score = 0
+		return eval
+	}
+
+	if cr.window != nil {
+		eval.score += scoreWindowMatch
+	}
+	eval.matched = true
+	return eval
 }
 
-// GetConfig 获取当前配置
-func (ms *MatcherService) GetConfig() *Config {
-	ms.ruleMutex.RLock()
-	defer ms.ruleMutex.RUnlock()
+// better 判断评估结果 a 是否优于 b：管理策略中的规则总是优先，其次得分高者优先，再其次优先级数字小者，
+// 再其次配置方案的规则优先于共享规则，最后按配置中的顺序：
+// 主配置文件中的规则在前，rules.d 中的规则按文件名顺序排在之后
+func (a ruleEvaluation) better(b ruleEvaluation) bool {
+	if aPolicy, bPolicy := a.cr.ref.source == policyRuleSource, b.cr.ref.source == policyRuleSource; aPolicy != bPolicy {
+		return aPolicy
+	}
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	if a.cr.rule.Priority != b.cr.rule.Priority {
+		return a.cr.rule.Priority < b.cr.rule.Priority
+	}
+	if (a.cr.ref.profile != "") != (b.cr.ref.profile != "") {
+		return a.cr.ref.profile != ""
+	}
+	return a.cr.index < b.cr.index
+}
+
+// inProfile 判断规则在指定的配置方案下是否参与匹配：共享规则始终参与，方案规则只在方案启用时参与
+func (cr *compiledRule) inProfile(profile string) bool {
+	return cr.ref.profile == "" || cr.ref.profile == profile
+}
+
+// bestMatch 返回快照中得分最高的规则评估结果，没有匹配时返回 nil
+func (s *configSnapshot) bestMatch(window *WindowInfo, profile string, now time.Time) *ruleEvaluation {
+	var best *ruleEvaluation
+	for i := range s.compiled.rules {
+		if !s.compiled.rules[i].inProfile(profile) {
+			continue
+		}
+		eval := s.compiled.rules[i].evaluate(window, now)
+		if !eval.matched {
+			continue
+		}
+		if best == nil || eval.better(*best) {
+			best = &eval
+		}
+	}
+	return best
+}
 
-	if ms.config == nil {
+// MatchWindow 匹配窗口并返回对应的规则
+// 所有规则都会参与评分，结果与规则表的遍历顺序无关
+func (ms *MatcherService) MatchWindow(window *WindowInfo) *Rule {
+	snapshot := ms.current()
+	if window == nil || snapshot == nil || snapshot.isIgnored(window) {
 		return nil
 	}
 
-	// 返回配置的深拷贝
-	configCopy := *ms.config
-	return &configCopy
+	now := ms.currentTime()
+	profile, _ := snapshot.activeProfile(ms.hostname, now)
+	best := snapshot.bestMatch(window, profile, now)
+	if best == nil {
+		return nil
+	}
+	rule := best.cr.rule.Clone()
+	return &rule
 }
 
-// AddRule 添加新规则
-func (ms *MatcherService) AddRule(rule Rule) error {
-	config := ms.GetConfig()
-	if config == nil {
-		return fmt.Errorf("config not loaded")
+// GetConfig 获取当前配置的深拷贝，修改返回的配置不会影响当前配置
+func (ms *MatcherService) GetConfig() *Config {
+	snapshot := ms.current()
+	if snapshot == nil {
+		return nil
 	}
 
-	// 设置默认值
-	if rule.Priority == 0 {
-		rule.Priority = len(config.Rules) + 1
+	configCopy := snapshot.config.Clone()
+
+	// 附加规则的熔断状态
+	for _, ref := range configCopy.ruleRefs() {
+		rule := configCopy.rule(ref)
+		rule.Breaker = ms.breaker.State(ruleKey(rule))
 	}
+	return configCopy
+}
+
+// ruleKey 生成规则的唯一标识，用于熔断状态跟踪
+func ruleKey(rule *Rule) string {
+	return rule.ID
+}
 
-	config.Rules = append(config.Rules, rule)
-	return ms.SaveConfig(config)
+// AllowRule 判断规则当前是否允许执行（未被熔断暂停，或已到重试时间的一次试探执行）
+func (ms *MatcherService) AllowRule(rule *Rule) bool {
+	return ms.breaker.Allow(ruleKey(rule))
 }
 
-// UpdateRule 更新规则
-func (ms *MatcherService) UpdateRule(index int, rule Rule) error {
+// ReportRuleResult 报告规则执行结果
+// 返回 tripped 表示本次失败导致规则被暂停，recovered 表示暂停的规则已恢复
+func (ms *MatcherService) ReportRuleResult(rule *Rule, err error) (tripped bool, recovered bool) {
+	key := ruleKey(rule)
+	if err != nil {
+		return ms.breaker.RecordFailure(key, err), false
+	}
+	return false, ms.breaker.RecordSuccess(key)
+}
+
+// GetSuspendedRules 获取当前被熔断暂停的规则
+func (ms *MatcherService) GetSuspendedRules() []Rule {
 	config := ms.GetConfig()
 	if config == nil {
-		return fmt.Errorf("config not loaded")
+		return nil
 	}
 
-	if index < 0 || index >= len(config.Rules) {
-		return fmt.Errorf("rule index out of range")
+	var suspended []Rule
+	for _, ref := range config.ruleRefs() {
+		if rule := config.rule(ref); rule.Breaker != nil && rule.Breaker.Suspended {
+			suspended = append(suspended, *rule)
+		}
 	}
+	return suspended
+}
 
-	config.Rules[index] = rule
-	return ms.SaveConfig(config)
+// findRule 查找指定ID的规则在配置中的位置，包括各配置方案中的规则
+func (c *Config) findRule(id string) (ruleRef, error) {
+	for _, ref := range c.ruleRefs() {
+		if c.rule(ref).ID == id {
+			return ref, nil
+		}
+	}
+	return ruleRef{}, fmt.Errorf("rule not found: %s", id)
 }
 
-// DeleteRule 删除规则
-func (ms *MatcherService) DeleteRule(index int) error {
-	config := ms.GetConfig()
-	if config == nil {
-		return fmt.Errorf("config not loaded")
+// GetRule 获取指定ID的规则
+func (ms *MatcherService) GetRule(id string) (*Rule, error) {
+	snapshot := ms.current()
+	if snapshot == nil {
+		return nil, fmt.Errorf("config not loaded")
 	}
 
-	if index < 0 || index >= len(config.Rules) {
-		return fmt.Errorf("rule index out of range")
+	ref, err := snapshot.config.findRule(id)
+	if err != nil {
+		return nil, err
 	}
 
-	config.Rules = append(config.Rules[:index], config.Rules[index+1:]...)
-	return ms.SaveConfig(config)
+	rule := snapshot.config.rule(ref).Clone()
+	rule.Breaker = ms.breaker.State(ruleKey(&rule))
+	return &rule, nil
+}
+
+// AddRule 添加新的共享规则，返回分配了ID的规则
+func (ms *MatcherService) AddRule(rule Rule) (*Rule, error) {
+	if rule.Source != "" {
+		return nil, fmt.Errorf("%w: cannot add rules to %s/%s", ErrReadOnlyRule, ruleSourceDirName, rule.Source)
+	}
+
+	ms.ruleMutex.Lock()
+	defer ms.ruleMutex.Unlock()
+
+	config, err := ms.updateLocked(func(config *Config) error {
+		// 设置默认值
+		if rule.Priority == 0 {
+			rule.Priority = len(config.Rules) + 1
+		}
+
+		// 新规则总是使用新生成的随机ID，删除后再添加同样的规则也不会沿用旧规则的ID
+		rule.ID = newRuleID()
+		config.Rules = append(config.Rules, rule.Clone())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	added := config.Rules[len(config.Rules)-1].Clone()
+	return &added, nil
+}
+
+// UpdateRule 更新指定ID的规则，规则ID保持不变，rules.d 中的规则和被管理策略锁定的规则不能修改
+func (ms *MatcherService) UpdateRule(id string, rule Rule) error {
+	return ms.Update(func(config *Config) error {
+		ref, err := config.editableRule(id)
+		if err != nil {
+			return err
+		}
+
+		rule = rule.Clone()
+		rule.ID = id
+		*config.rule(ref) = rule
+		return nil
+	})
+}
+
+// DeleteRule 删除指定ID的规则，rules.d 中的规则和被管理策略锁定的规则不能删除
+func (ms *MatcherService) DeleteRule(id string) error {
+	return ms.Update(func(config *Config) error {
+		ref, err := config.editableRule(id)
+		if err != nil {
+			return err
+		}
+
+		config.removeRule(ref)
+		return nil
+	})
+}
+
+// editableRule 查找可以修改的规则，规则被锁定或位于 rules.d 中时返回错误
+func (c *Config) editableRule(id string) (ruleRef, error) {
+	ref, err := c.findRule(id)
+	if err != nil {
+		return ruleRef{}, err
+	}
+	if c.rule(ref).Locked {
+		return ruleRef{}, lockedRuleError(id, ref, c)
+	}
+	if ref.source != "" {
+		return ruleRef{}, readOnlyRuleError(id, ref)
+	}
+	return ref, nil
+}
+
+// SetNoticeCallback 设置提示回调，用于把加载配置过程中的提示（例如写回配置文件失败）记录到日志
+func (ms *MatcherService) SetNoticeCallback(callback func(level LogLevel, message string)) {
+	ms.ruleMutex.Lock()
+	defer ms.ruleMutex.Unlock()
+	ms.onNotice = callback
+}
+
+// noticeLocked 通过回调报告提示，没有设置回调时忽略，调用方需持有锁
+func (ms *MatcherService) noticeLocked(level LogLevel, message string) {
+	if ms.onNotice != nil {
+		ms.onNotice(level, message)
+	}
 }
 
 // SetRuleMatchCallback 设置规则匹配回调
@@ -351,13 +1255,19 @@ func (ms *MatcherService) SetRuleMatchCallback(callback func(*Rule, *WindowInfo)
 
 // GetAppRules 获取特定应用的规则
 func (ms *MatcherService) GetAppRules(appName string) []Rule {
-	ms.ruleMutex.RLock()
-	defer ms.ruleMutex.RUnlock()
+	snapshot := ms.current()
+	if snapshot == nil {
+		return nil
+	}
 
+	profile, _ := snapshot.activeProfile(ms.hostname, ms.currentTime())
 	var appRules []Rule
-	if rules, exists := ms.ruleMap[appName]; exists {
-		for _, rule := range rules {
-			appRules = append(appRules, rule)
+	for _, cr := range snapshot.compiled.rules {
+		if !cr.inProfile(profile) {
+			continue
+		}
+		if _, mode, _ := cr.appScore(appName); mode != "" && mode != MatchFuzzy {
+			appRules = append(appRules, cr.rule.Clone())
 		}
 	}
 
@@ -365,6 +1275,11 @@ func (ms *MatcherService) GetAppRules(appName string) []Rule {
 }
 
 // ReloadConfig 重新加载配置文件
+// 手动重新加载时清空熔断状态，让被暂停的规则立即重试
 func (ms *MatcherService) ReloadConfig() error {
-	return ms.LoadConfig()
+	if err := ms.LoadConfig(); err != nil {
+		return err
+	}
+	ms.breaker.Reset()
+	return nil
 }
\ No newline at end of file
@@ -0,0 +1,515 @@
+package services
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestMatcher 在临时目录中写入配置文件并加载，不使用系统配置目录
+func newTestMatcher(t *testing.T, rules []Rule) *MatcherService {
+	t.Helper()
+
+	ms, err := loadTestConfig(t, map[string]interface{}{
+		"version": currentConfigVersion,
+		"rules":   rules,
+	})
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	return ms
+}
+
+// loadTestConfig 将 config 编码为 JSON 写入临时目录并加载，返回加载时的错误
+func loadTestConfig(t *testing.T, config interface{}) (*MatcherService, error) {
+	t.Helper()
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ms := NewMatcherService(path)
+	ms.SetSystemConfigDir("")
+	return ms, ms.LoadConfig()
+}
+
+// testRule 创建测试用的规则
+func testRule(id, app, appMatch string, priority int) Rule {
+	return Rule{ID: id, AppName: app, AppMatch: appMatch, Input: InputList{"com.test." + id}, Enabled: true, Priority: priority}
+}
+
+func TestMatchWindowScoring(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  []Rule
+		window WindowInfo
+		want   string
+	}{
+		{
+			name: "exact beats prefix",
+			rules: []Rule{
+				testRule("prefix", "Saf", MatchPrefix, 1),
+				testRule("exact", "Safari", MatchExact, 5),
+			},
+			window: WindowInfo{AppName: "Safari"},
+			want:   "exact",
+		},
+		{
+			name: "prefix beats glob",
+			rules: []Rule{
+				testRule("glob", "Goo*", MatchGlob, 1),
+				testRule("prefix", "Goo", MatchPrefix, 5),
+			},
+			window: WindowInfo{AppName: "Google Chrome"},
+			want:   "prefix",
+		},
+		{
+			name: "glob beats contains",
+			rules: []Rule{
+				testRule("contains", "Chrome", MatchContains, 1),
+				testRule("glob", "*Chrome", MatchGlob, 5),
+			},
+			window: WindowInfo{AppName: "Google Chrome"},
+			want:   "glob",
+		},
+		{
+			name: "regex beats contains",
+			rules: []Rule{
+				testRule("contains", "Chrome", MatchContains, 1),
+				testRule("regex", "chrome$", MatchRegex, 5),
+			},
+			window: WindowInfo{AppName: "Google Chrome"},
+			want:   "regex",
+		},
+		{
+			name: "glob and regex tie, priority decides",
+			rules: []Rule{
+				testRule("glob", "*Chrome", MatchGlob, 2),
+				testRule("regex", "chrome$", MatchRegex, 1),
+			},
+			window: WindowInfo{AppName: "Google Chrome"},
+			want:   "regex",
+		},
+		{
+			name: "contains beats fuzzy",
+			rules: []Rule{
+				testRule("fuzzy", "Chrome", "", 1),
+				testRule("contains", "oogle", MatchContains, 5),
+			},
+			window: WindowInfo{AppName: "Google Chrome"},
+			want:   "contains",
+		},
+		{
+			name: "fuzzy matches whole words",
+			rules: []Rule{
+				testRule("fuzzy", "Chrome", "", 1),
+			},
+			window: WindowInfo{AppName: "Google Chrome"},
+			want:   "fuzzy",
+		},
+		{
+			name: "fuzzy does not match partial words",
+			rules: []Rule{
+				testRule("fuzzy", "Term", "", 1),
+			},
+			window: WindowInfo{AppName: "Terminal"},
+			want:   "",
+		},
+		{
+			name: "unset mode tries exact first",
+			rules: []Rule{
+				testRule("prefix", "Term", MatchPrefix, 1),
+				testRule("default", "Terminal", "", 5),
+			},
+			window: WindowInfo{AppName: "Terminal"},
+			want:   "default",
+		},
+		{
+			name: "window match adds bonus within the same mode",
+			rules: []Rule{
+				testRule("plain", "Chrome", MatchContains, 1),
+				func() Rule {
+					r := testRule("window", "Chrome", MatchContains, 5)
+					r.WindowName = "GitHub"
+					return r
+				}(),
+			},
+			window: WindowInfo{AppName: "Google Chrome", WindowName: "GitHub - Pull requests"},
+			want:   "window",
+		},
+		{
+			name: "window bonus does not cross modes",
+			rules: []Rule{
+				func() Rule {
+					r := testRule("window", "Chrome", MatchContains, 1)
+					r.WindowName = "GitHub"
+					return r
+				}(),
+				testRule("prefix", "Google", MatchPrefix, 5),
+			},
+			window: WindowInfo{AppName: "Google Chrome", WindowName: "GitHub"},
+			want:   "prefix",
+		},
+		{
+			name: "window mismatch excludes the rule",
+			rules: []Rule{
+				testRule("plain", "Chrome", MatchContains, 5),
+				func() Rule {
+					r := testRule("window", "Chrome", MatchContains, 1)
+					r.WindowName = "GitHub"
+					return r
+				}(),
+			},
+			window: WindowInfo{AppName: "Google Chrome", WindowName: "Inbox"},
+			want:   "plain",
+		},
+		{
+			name: "equal score, lower priority wins",
+			rules: []Rule{
+				testRule("second", "Terminal", MatchExact, 2),
+				testRule("first", "Terminal", MatchExact, 1),
+			},
+			window: WindowInfo{AppName: "Terminal"},
+			want:   "first",
+		},
+		{
+			name: "equal score and priority, config order wins",
+			rules: []Rule{
+				testRule("earlier", "Terminal", MatchExact, 1),
+				testRule("later", "Terminal", MatchExact, 1),
+			},
+			window: WindowInfo{AppName: "Terminal"},
+			want:   "earlier",
+		},
+		{
+			name: "matching ignores case",
+			rules: []Rule{
+				testRule("exact", "terminal", MatchExact, 1),
+			},
+			window: WindowInfo{AppName: "Terminal"},
+			want:   "exact",
+		},
+		{
+			name: "disabled rules are skipped",
+			rules: []Rule{
+				func() Rule {
+					r := testRule("disabled", "Terminal", MatchExact, 1)
+					r.Enabled = false
+					return r
+				}(),
+				testRule("enabled", "Term", MatchPrefix, 5),
+			},
+			window: WindowInfo{AppName: "Terminal"},
+			want:   "enabled",
+		},
+		{
+			name: "no rule matches",
+			rules: []Rule{
+				testRule("exact", "Safari", MatchExact, 1),
+			},
+			window: WindowInfo{AppName: "Terminal"},
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := newTestMatcher(t, tt.rules)
+			window := tt.window
+
+			got := ""
+			if rule := ms.MatchWindow(&window); rule != nil {
+				got = rule.ID
+			}
+			if got != tt.want {
+				t.Errorf("MatchWindow(%+v) = %q, want %q", tt.window, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchWindowStable(t *testing.T) {
+	rules := []Rule{
+		testRule("contains", "Chrome", MatchContains, 1),
+		testRule("glob", "*Chrome", MatchGlob, 1),
+		testRule("regex", "chrome$", MatchRegex, 1),
+		testRule("fuzzy", "Chrome", "", 1),
+		testRule("prefix-low", "Google", MatchPrefix, 2),
+		testRule("prefix", "Google", MatchPrefix, 1),
+		testRule("prefix-late", "Google", MatchPrefix, 1),
+	}
+	window := &WindowInfo{AppName: "Google Chrome"}
+
+	// 多次加载和匹配的结果都相同
+	for run := 0; run < 20; run++ {
+		ms := newTestMatcher(t, rules)
+		for i := 0; i < 50; i++ {
+			rule := ms.MatchWindow(window)
+			if rule == nil || rule.ID != "prefix" {
+				t.Fatalf("run %d, match %d: got %v, want rule prefix", run, i, rule)
+			}
+		}
+	}
+}
+
+func TestLoadConfigInputAliases(t *testing.T) {
+	tests := []struct {
+		name    string
+		inputs  map[string]interface{}
+		input   interface{}
+		wantErr string
+	}{
+		{
+			name:   "defined alias",
+			inputs: map[string]interface{}{"jp": "com.apple.inputmethod.Kotoeri.Japanese"},
+			input:  "jp",
+		},
+		{
+			name:  "input ID",
+			input: "com.apple.keylayout.ABC",
+		},
+		{
+			name:    "undefined alias",
+			inputs:  map[string]interface{}{"cn": "com.tencent.inputmethod.wetype.pinyin"},
+			input:   []string{"com.apple.keylayout.ABC", "jp"},
+			wantErr: `unknown input alias "jp"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms, err := loadTestConfig(t, map[string]interface{}{
+				"version": currentConfigVersion,
+				"inputs":  tt.inputs,
+				"rules":   []interface{}{map[string]interface{}{"app": "Terminal", "input": tt.input, "enabled": true}},
+			})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("LoadConfig: %v", err)
+				}
+				rule := ms.MatchWindow(&WindowInfo{AppName: "Terminal"})
+				if rule == nil {
+					t.Fatal("MatchWindow(Terminal) = nil")
+				}
+				if resolved := ms.ResolveInputs(rule.Input); len(resolved) != 1 || !strings.Contains(resolved[0], ".") {
+					t.Errorf("ResolveInputs(%v) = %v, want an input ID", rule.Input, resolved)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadConfig error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAddRuleUsesNewIDs(t *testing.T) {
+	ms := newTestMatcher(t, []Rule{testRule("terminal", "Terminal", MatchExact, 1)})
+	rule := Rule{ID: "terminal", AppName: "Safari", Input: InputList{"com.apple.keylayout.ABC"}, Enabled: true}
+
+	first, err := ms.AddRule(rule)
+	if err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	if first.ID == "" || first.ID == "terminal" {
+		t.Fatalf("added rule ID = %q, want a new ID", first.ID)
+	}
+
+	// 删除后再添加同样的规则，不沿用旧规则的ID
+	if err := ms.DeleteRule(first.ID); err != nil {
+		t.Fatalf("DeleteRule: %v", err)
+	}
+	second, err := ms.AddRule(rule)
+	if err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	if second.ID == first.ID {
+		t.Errorf("re-added rule reused the deleted rule's ID %q", first.ID)
+	}
+
+	third, err := ms.AddRule(rule)
+	if err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	if third.ID == second.ID {
+		t.Errorf("identical rules got the same ID %q", third.ID)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		fallback  map[string]interface{}
+		wantErr   string
+		wantMode  string
+		wantInput string
+	}{
+		{
+			name:     "defaults to none",
+			fallback: nil,
+			wantMode: FallbackNone,
+		},
+		{
+			name:      "default input",
+			fallback:  map[string]interface{}{"mode": FallbackDefault, "input": "com.apple.keylayout.ABC"},
+			wantMode:  FallbackDefault,
+			wantInput: "com.apple.keylayout.ABC",
+		},
+		{
+			name:     "restore",
+			fallback: map[string]interface{}{"mode": FallbackRestore},
+			wantMode: FallbackRestore,
+		},
+		{
+			name:     "default without input",
+			fallback: map[string]interface{}{"mode": FallbackDefault},
+			wantErr:  "general.fallback.input: required",
+		},
+		{
+			name:     "unknown mode",
+			fallback: map[string]interface{}{"mode": "previous"},
+			wantErr:  `general.fallback.mode: unknown mode "previous"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := map[string]interface{}{
+				"version": currentConfigVersion,
+				"rules":   []Rule{testRule("terminal", "Terminal", MatchExact, 1)},
+			}
+			if tt.fallback != nil {
+				config["general"] = map[string]interface{}{"fallback": tt.fallback}
+			}
+			ms, err := loadTestConfig(t, config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadConfig error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+
+			// 有规则匹配时不使用兜底行为，没有匹配时给出兜底决策
+			if explanation := ms.Explain(&WindowInfo{AppName: "Terminal"}); explanation.Fallback != nil {
+				t.Errorf("matched window got a fallback: %+v", explanation.Fallback)
+			}
+			explanation := ms.Explain(&WindowInfo{AppName: "Safari"})
+			if explanation.Decision != nil || explanation.Fallback == nil {
+				t.Fatalf("Explain(Safari) = decision %+v, fallback %+v", explanation.Decision, explanation.Fallback)
+			}
+			if explanation.Fallback.Mode != tt.wantMode || explanation.Fallback.Input.String() != tt.wantInput {
+				t.Errorf("fallback = %+v, want mode %s input %q", explanation.Fallback, tt.wantMode, tt.wantInput)
+			}
+		})
+	}
+}
+
+func TestMatchWindowExclusions(t *testing.T) {
+	chrome := func(exclude *RuleExclude) Rule {
+		r := testRule("chrome", "Chrome", MatchContains, 1)
+		r.Exclude = exclude
+		return r
+	}
+
+	tests := []struct {
+		name    string
+		exclude *RuleExclude
+		window  WindowInfo
+		want    bool
+	}{
+		{
+			name:    "excluded window title",
+			exclude: &RuleExclude{Window: []string{"DevTools"}},
+			window:  WindowInfo{AppName: "Google Chrome", WindowName: "DevTools - example.com"},
+			want:    false,
+		},
+		{
+			name:    "other window titles still match",
+			exclude: &RuleExclude{Window: []string{"DevTools"}},
+			window:  WindowInfo{AppName: "Google Chrome", WindowName: "example.com"},
+			want:    true,
+		},
+		{
+			name:    "excluded app with glob",
+			exclude: &RuleExclude{App: []string{"*Canary"}},
+			window:  WindowInfo{AppName: "Google Chrome Canary"},
+			want:    false,
+		},
+		{
+			name:    "excluded app path",
+			exclude: &RuleExclude{AppPath: []string{"/Applications/Work/"}},
+			window:  WindowInfo{AppName: "Google Chrome", AppPath: "/Applications/Work/Google Chrome.app"},
+			want:    false,
+		},
+		{
+			name:    "exclusion match mode",
+			exclude: &RuleExclude{Window: []string{"^Inbox"}, Match: MatchRegex},
+			window:  WindowInfo{AppName: "Google Chrome", WindowName: "Re: Inbox"},
+			want:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := newTestMatcher(t, []Rule{chrome(tt.exclude)})
+			window := tt.window
+			if got := ms.MatchWindow(&window) != nil; got != tt.want {
+				t.Errorf("MatchWindow(%+v) matched = %v, want %v", tt.window, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreApps(t *testing.T) {
+	ms, err := loadTestConfig(t, map[string]interface{}{
+		"version": currentConfigVersion,
+		"general": map[string]interface{}{"ignoreApps": []string{"Raycast", "Screenshot*"}},
+		"rules":   []Rule{testRule("terminal", "Terminal", MatchExact, 1)},
+	})
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	tests := []struct {
+		app  string
+		want bool
+	}{
+		{"Raycast", true},
+		{"raycast", true},
+		{"Raycast Helper", false},
+		{"Screenshot Tool", true},
+		{"Terminal", false},
+	}
+	for _, tt := range tests {
+		if got := ms.IsIgnored(&WindowInfo{AppName: tt.app}); got != tt.want {
+			t.Errorf("IsIgnored(%s) = %v, want %v", tt.app, got, tt.want)
+		}
+	}
+
+	if explanation := ms.Explain(&WindowInfo{AppName: "Raycast"}); !explanation.Ignored || explanation.Fallback != nil {
+		t.Errorf("Explain(Raycast) = ignored %v, fallback %+v, want ignored without a fallback", explanation.Ignored, explanation.Fallback)
+	}
+}
+
+func TestLoadConfigRejectsInvalidExclusions(t *testing.T) {
+	_, err := loadTestConfig(t, map[string]interface{}{
+		"version": currentConfigVersion,
+		"rules": []interface{}{map[string]interface{}{
+			"app": "Chrome", "input": "com.apple.keylayout.ABC", "enabled": true,
+			"exclude": map[string]interface{}{"window": []string{"ok", "("}, "match": MatchRegex},
+		}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "rules[0].exclude.window[1]") {
+		t.Errorf("LoadConfig error = %v, want an error at rules[0].exclude.window[1]", err)
+	}
+}
@@ -0,0 +1,360 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 指标阶段名称
+const (
+	StageWindowDetect  = "window_detect"  // 获取活动窗口
+	StageRuleMatch     = "rule_match"     // 规则匹配
+	StageDebounce      = "debounce"       // 切换延迟
+	StageBackendSwitch = "backend_switch" // 后端切换输入法
+	StageTotal         = "total"          // 从检测到窗口变化到切换完成
+)
+
+// 切换结果
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeSkipped = "skipped"
+)
+
+// latencyBuckets 延迟直方图的桶上限（毫秒）
+var latencyBuckets = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// Histogram 延迟直方图（单位：毫秒）
+type Histogram struct {
+	Buckets []float64 `json:"buckets"` // 桶上限
+	Counts  []int64   `json:"counts"`  // 每个桶的计数，最后一个元素统计超过最大桶上限的样本
+	Count   int64     `json:"count"`
+	Sum     float64   `json:"sum"`
+	Min     float64   `json:"min"`
+	Max     float64   `json:"max"`
+	P50     float64   `json:"p50"` // 按桶估算的中位数
+	P95     float64   `json:"p95"` // 按桶估算的95分位
+}
+
+// newHistogram 创建空的直方图
+func newHistogram() *Histogram {
+	return &Histogram{
+		Buckets: latencyBuckets,
+		Counts:  make([]int64, len(latencyBuckets)+1),
+	}
+}
+
+// observe 记录一个样本
+func (h *Histogram) observe(d time.Duration) {
+	value := float64(d) / float64(time.Millisecond)
+
+	index := len(h.Buckets)
+	for i, upper := range h.Buckets {
+		if value <= upper {
+			index = i
+			break
+		}
+	}
+	h.Counts[index]++
+
+	if h.Count == 0 || value < h.Min {
+		h.Min = value
+	}
+	if value > h.Max {
+		h.Max = value
+	}
+	h.Count++
+	h.Sum += value
+}
+
+// quantile 根据桶计数估算分位数，返回所在桶的上限
+func (h *Histogram) quantile(q float64) float64 {
+	if h.Count == 0 {
+		return 0
+	}
+
+	target := int64(q * float64(h.Count))
+	if target < 1 {
+		target = 1
+	}
+	var cumulative int64
+	for i, count := range h.Counts {
+		cumulative += count
+		if cumulative >= target {
+			if i < len(h.Buckets) {
+				return h.Buckets[i]
+			}
+			break
+		}
+	}
+	return h.Max
+}
+
+// copy 复制直方图并计算分位数
+func (h *Histogram) copy() *Histogram {
+	c := *h
+	c.Counts = append([]int64(nil), h.Counts...)
+	c.P50 = h.quantile(0.5)
+	c.P95 = h.quantile(0.95)
+	return &c
+}
+
+// OutcomeMetrics 某个后端或规则的切换结果计数和耗时
+type OutcomeMetrics struct {
+	Label   string     `json:"label,omitempty"` // 规则的显示名称，例如 "Terminal -> com.apple.keylayout.ABC"
+	Success int64      `json:"success"`
+	Failure int64      `json:"failure"`
+	Skipped int64      `json:"skipped"`
+	Latency *Histogram `json:"latency"`
+}
+
+// newOutcomeMetrics 创建空的结果统计
+func newOutcomeMetrics() *OutcomeMetrics {
+	return &OutcomeMetrics{Latency: newHistogram()}
+}
+
+// record 记录一次结果
+func (om *OutcomeMetrics) record(outcome string, d time.Duration) {
+	switch outcome {
+	case OutcomeSuccess:
+		om.Success++
+	case OutcomeFailure:
+		om.Failure++
+	case OutcomeSkipped:
+		om.Skipped++
+		return
+	}
+	om.Latency.observe(d)
+}
+
+// copy 复制结果统计
+func (om *OutcomeMetrics) copy() *OutcomeMetrics {
+	c := *om
+	c.Latency = om.Latency.copy()
+	return &c
+}
+
+// DailyMetrics 按天聚合的指标
+type DailyMetrics struct {
+	Date     string                     `json:"date"`     // 日期 YYYY-MM-DD
+	Stages   map[string]*Histogram      `json:"stages"`   // 阶段 -> 耗时
+	Backends map[string]*OutcomeMetrics `json:"backends"` // 后端 -> 切换结果
+	Rules    map[string]*OutcomeMetrics `json:"rules"`    // 规则ID -> 端到端结果
+}
+
+// newDailyMetrics 创建指定日期的空指标
+func newDailyMetrics(date string) *DailyMetrics {
+	return &DailyMetrics{
+		Date:     date,
+		Stages:   make(map[string]*Histogram),
+		Backends: make(map[string]*OutcomeMetrics),
+		Rules:    make(map[string]*OutcomeMetrics),
+	}
+}
+
+// copy 深拷贝指标
+func (dm *DailyMetrics) copy() *DailyMetrics {
+	c := newDailyMetrics(dm.Date)
+	for stage, histogram := range dm.Stages {
+		c.Stages[stage] = histogram.copy()
+	}
+	for backend, metrics := range dm.Backends {
+		c.Backends[backend] = metrics.copy()
+	}
+	for rule, metrics := range dm.Rules {
+		c.Rules[rule] = metrics.copy()
+	}
+	return c
+}
+
+// MetricsService 切换耗时和结果统计服务
+type MetricsService struct {
+	metricsDir   string
+	current      *DailyMetrics
+	metricsMutex sync.Mutex
+	flushTicker  *time.Ticker
+	stopChan     chan bool
+	now          func() time.Time
+}
+
+// NewMetricsService 创建新的指标服务，每日聚合结果保存在 metricsDir 下
+func NewMetricsService(metricsDir string) *MetricsService {
+	return &MetricsService{
+		metricsDir: metricsDir,
+		stopChan:   make(chan bool),
+		now:        time.Now,
+	}
+}
+
+// Start 启动指标服务，加载当天已有的聚合数据并定时保存
+func (ms *MetricsService) Start() error {
+	if err := os.MkdirAll(ms.metricsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create metrics directory: %v", err)
+	}
+
+	ms.metricsMutex.Lock()
+	ms.current = ms.loadDay(ms.today())
+	ms.metricsMutex.Unlock()
+
+	ms.flushTicker = time.NewTicker(time.Minute)
+	go ms.flushRoutine()
+
+	return nil
+}
+
+// Stop 停止指标服务并保存数据
+func (ms *MetricsService) Stop() {
+	if ms.flushTicker != nil {
+		ms.flushTicker.Stop()
+	}
+	close(ms.stopChan)
+
+	ms.metricsMutex.Lock()
+	defer ms.metricsMutex.Unlock()
+	ms.save()
+}
+
+// flushRoutine 定时保存协程
+func (ms *MetricsService) flushRoutine() {
+	for {
+		select {
+		case <-ms.flushTicker.C:
+			ms.metricsMutex.Lock()
+			ms.save()
+			ms.metricsMutex.Unlock()
+		case <-ms.stopChan:
+			return
+		}
+	}
+}
+
+// RecordStage 记录某个阶段的耗时
+func (ms *MetricsService) RecordStage(stage string, d time.Duration) {
+	ms.metricsMutex.Lock()
+	defer ms.metricsMutex.Unlock()
+
+	day := ms.day()
+	histogram, exists := day.Stages[stage]
+	if !exists {
+		histogram = newHistogram()
+		day.Stages[stage] = histogram
+	}
+	histogram.observe(d)
+}
+
+// RecordBackendSwitch 记录后端切换结果和耗时
+func (ms *MetricsService) RecordBackendSwitch(backend, outcome string, d time.Duration) {
+	ms.metricsMutex.Lock()
+	defer ms.metricsMutex.Unlock()
+
+	day := ms.day()
+	metrics, exists := day.Backends[backend]
+	if !exists {
+		metrics = newOutcomeMetrics()
+		day.Backends[backend] = metrics
+	}
+	metrics.record(outcome, d)
+}
+
+// RecordRuleOutcome 记录规则的端到端结果和耗时，按规则ID统计，label 为显示名称
+func (ms *MetricsService) RecordRuleOutcome(ruleID, label, outcome string, d time.Duration) {
+	ms.metricsMutex.Lock()
+	defer ms.metricsMutex.Unlock()
+
+	day := ms.day()
+	metrics, exists := day.Rules[ruleID]
+	if !exists {
+		metrics = newOutcomeMetrics()
+		day.Rules[ruleID] = metrics
+	}
+	metrics.Label = label
+	metrics.record(outcome, d)
+}
+
+// GetMetrics 获取当天指标的快照
+func (ms *MetricsService) GetMetrics() *DailyMetrics {
+	ms.metricsMutex.Lock()
+	defer ms.metricsMutex.Unlock()
+	return ms.day().copy()
+}
+
+// GetDailyMetrics 获取指定日期（YYYY-MM-DD）已保存的指标
+func (ms *MetricsService) GetDailyMetrics(date string) (*DailyMetrics, error) {
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return nil, fmt.Errorf("invalid date: %s", date)
+	}
+
+	ms.metricsMutex.Lock()
+	defer ms.metricsMutex.Unlock()
+
+	if date == ms.day().Date {
+		return ms.current.copy(), nil
+	}
+
+	data, err := ioutil.ReadFile(ms.dayPath(date))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics: %v", err)
+	}
+	day := newDailyMetrics(date)
+	if err := json.Unmarshal(data, day); err != nil {
+		return nil, fmt.Errorf("failed to parse metrics: %v", err)
+	}
+	return day.copy(), nil
+}
+
+// day 返回当天的指标，跨天时保存前一天的数据并重新开始，调用方需持有锁
+func (ms *MetricsService) day() *DailyMetrics {
+	today := ms.today()
+	if ms.current == nil {
+		ms.current = newDailyMetrics(today)
+	} else if ms.current.Date != today {
+		ms.save()
+		ms.current = newDailyMetrics(today)
+	}
+	return ms.current
+}
+
+// today 返回当天日期
+func (ms *MetricsService) today() string {
+	return ms.now().Format("2006-01-02")
+}
+
+// dayPath 返回某天指标文件路径
+func (ms *MetricsService) dayPath(date string) string {
+	return filepath.Join(ms.metricsDir, date+".json")
+}
+
+// loadDay 加载某天已保存的指标，不存在或无法解析时返回空指标
+func (ms *MetricsService) loadDay(date string) *DailyMetrics {
+	day := newDailyMetrics(date)
+	data, err := ioutil.ReadFile(ms.dayPath(date))
+	if err != nil {
+		return day
+	}
+	if err := json.Unmarshal(data, day); err != nil {
+		fmt.Printf("Failed to parse metrics for %s: %v\n", date, err)
+		return newDailyMetrics(date)
+	}
+	return day
+}
+
+// save 保存当前指标到文件，调用方需持有锁
+func (ms *MetricsService) save() {
+	if ms.current == nil {
+		return
+	}
+
+	data, err := json.MarshalIndent(ms.current.copy(), "", "  ")
+	if err != nil {
+		fmt.Printf("Failed to marshal metrics: %v\n", err)
+		return
+	}
+	if err := ioutil.WriteFile(ms.dayPath(ms.current.Date), data, 0644); err != nil {
+		fmt.Printf("Failed to write metrics: %v\n", err)
+	}
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// currentConfigVersion 当前的配置文件格式版本
+const currentConfigVersion = 1
+
+// configMigration 将配置从 from 版本升级到 from+1 版本
+// 迁移作用于通用的 JSON 对象，不依赖当前的 Config 结构，旧版本的字段即使已被删除也能处理
+type configMigration struct {
+	from        int
+	description string
+	apply       func(config map[string]interface{}) error
+}
+
+// configMigrations 按版本顺序排列的迁移步骤，新增版本时在末尾追加并增加 currentConfigVersion
+var configMigrations = []configMigration{
+	{
+		from:        0,
+		description: "add version field",
+		apply: func(config map[string]interface{}) error {
+			// 版本字段出现之前的格式与 v1 结构相同，只需要清理可能被写入文件的运行时状态
+			return forEachRule(config, func(rule map[string]interface{}) error {
+				delete(rule, "breaker")
+				return nil
+			})
+		},
+	},
+}
+
+// forEachRule 对共享规则和各配置方案中的规则依次调用 fn
+func forEachRule(config map[string]interface{}, fn func(rule map[string]interface{}) error) error {
+	apply := func(rules interface{}) error {
+		list, _ := rules.([]interface{})
+		for _, item := range list {
+			if rule, ok := item.(map[string]interface{}); ok {
+				if err := fn(rule); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := apply(config["rules"]); err != nil {
+		return err
+	}
+	profiles, _ := config["profiles"].(map[string]interface{})
+	for _, item := range profiles {
+		if profile, ok := item.(map[string]interface{}); ok {
+			if err := apply(profile["rules"]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// configVersion 读取配置文件中的版本号，没有版本字段的配置为 0
+func configVersion(config map[string]interface{}) (int, error) {
+	value, exists := config["version"]
+	if !exists {
+		return 0, nil
+	}
+	number, ok := value.(float64)
+	if !ok || number != float64(int(number)) || number < 0 {
+		return 0, configErrorf("version", "must be a non-negative integer")
+	}
+	return int(number), nil
+}
+
+// migrateConfigData 将配置文件内容升级到当前版本，返回升级后的内容和原始版本
+// 配置已是当前版本时原样返回
+func migrateConfigData(data []byte) ([]byte, int, error) {
+	var config map[string]interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse config: %s", describeJSONError(data, err))
+	}
+
+	version, err := configVersion(config)
+	if err != nil {
+		return nil, 0, err
+	}
+	if version > currentConfigVersion {
+		return nil, version, configErrorf("version", "config version %d is newer than supported version %d", version, currentConfigVersion)
+	}
+	if version == currentConfigVersion {
+		return data, version, nil
+	}
+
+	for _, migration := range configMigrations {
+		if migration.from < version {
+			continue
+		}
+		if err := migration.apply(config); err != nil {
+			return nil, version, fmt.Errorf("failed to migrate config from version %d (%s): %v", migration.from, migration.description, err)
+		}
+		config["version"] = migration.from + 1
+	}
+
+	migrated, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, version, fmt.Errorf("failed to marshal migrated config: %v", err)
+	}
+	return migrated, version, nil
+}
+
+// backupBeforeMigration 迁移前保存原始配置文件，文件名带有原版本号，不参与备份轮换
+func (ms *MatcherService) backupBeforeMigration(data []byte, version int) (string, error) {
+	if err := os.MkdirAll(ms.backupDir(), 0755); err != nil {
+		return "", err
+	}
+
+	base := filepath.Base(ms.configPath)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	name := fmt.Sprintf("%s-v%d%s", stem, version, ext)
+	path := filepath.Join(ms.backupDir(), name)
+	if _, err := os.Stat(path); err == nil {
+		name = fmt.Sprintf("%s-v%d-%s%s", stem, version, time.Now().Format(backupTimeFormat), ext)
+		path = filepath.Join(ms.backupDir(), name)
+	}
+
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
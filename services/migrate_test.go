@@ -0,0 +1,190 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// historicalFixtures 返回 testdata 中各历史版本的配置文件，文件名为 config-v<版本>.json
+func historicalFixtures(t *testing.T) map[int]string {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join("testdata", "config-v*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixtures := make(map[int]string)
+	for _, path := range paths {
+		var version int
+		if _, err := fmt.Sscanf(filepath.Base(path), "config-v%d.json", &version); err != nil {
+			t.Fatalf("unexpected fixture name %s", path)
+		}
+		fixtures[version] = path
+	}
+	for version := 0; version < currentConfigVersion; version++ {
+		if _, exists := fixtures[version]; !exists {
+			t.Errorf("missing fixture testdata/config-v%d.json", version)
+		}
+	}
+	return fixtures
+}
+
+func TestMigrateConfigData(t *testing.T) {
+	for version, path := range historicalFixtures(t) {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			migrated, from, err := migrateConfigData(data)
+			if err != nil {
+				t.Fatalf("migrateConfigData: %v", err)
+			}
+			if from != version {
+				t.Errorf("original version = %d, want %d", from, version)
+			}
+
+			var config map[string]interface{}
+			if err := json.Unmarshal(migrated, &config); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := configVersion(config); got != currentConfigVersion {
+				t.Errorf("migrated version = %d, want %d", got, currentConfigVersion)
+			}
+			forEachRule(config, func(rule map[string]interface{}) error {
+				if _, exists := rule["breaker"]; exists {
+					t.Errorf("rule %v still has runtime breaker state", rule["app"])
+				}
+				return nil
+			})
+
+			// 升级后的内容可以直接解析为当前的配置
+			parsed, _, err := parseConfig(migrated, nil)
+			if err != nil {
+				t.Fatalf("parseConfig: %v", err)
+			}
+			if len(parsed.Rules) == 0 {
+				t.Error("migrated config has no rules")
+			}
+		})
+	}
+}
+
+func TestMigrateConfigDataCurrentVersion(t *testing.T) {
+	data := []byte(fmt.Sprintf(`{"version": %d, "rules": []}`, currentConfigVersion))
+	migrated, version, err := migrateConfigData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != currentConfigVersion || !bytes.Equal(migrated, data) {
+		t.Errorf("current config should be returned unchanged, got version %d: %s", version, migrated)
+	}
+
+	newer := []byte(fmt.Sprintf(`{"version": %d}`, currentConfigVersion+1))
+	if _, _, err := migrateConfigData(newer); err == nil {
+		t.Error("expected an error for a config newer than supported")
+	}
+}
+
+func TestLoadConfigMigratesFixture(t *testing.T) {
+	for version, fixture := range historicalFixtures(t) {
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			original, err := ioutil.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := ioutil.WriteFile(path, original, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			ms := NewMatcherService(path)
+			ms.SetSystemConfigDir("")
+			var notices []string
+			ms.SetNoticeCallback(func(level LogLevel, message string) {
+				notices = append(notices, message)
+			})
+			if err := ms.LoadConfig(); err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+
+			config := ms.GetConfig()
+			if config.Version != currentConfigVersion {
+				t.Errorf("Version = %d, want %d", config.Version, currentConfigVersion)
+			}
+			for _, rule := range config.Rules {
+				if rule.ID == "" {
+					t.Errorf("rule %s has no ID", rule.AppName)
+				}
+				if len(rule.Input) != 1 {
+					t.Errorf("rule %s input = %v, want a single input", rule.AppName, rule.Input)
+				}
+				if rule.Breaker != nil {
+					t.Errorf("rule %s kept breaker state from the file: %+v", rule.AppName, rule.Breaker)
+				}
+			}
+			if rule := ms.MatchWindow(&WindowInfo{AppName: "Terminal"}); rule == nil || rule.Input.String() != "com.apple.keylayout.ABC" {
+				t.Errorf("MatchWindow(Terminal) = %v", rule)
+			}
+
+			// 原文件备份为 config-v<版本>.json，配置文件以当前版本写回
+			backup, err := ioutil.ReadFile(filepath.Join(ms.backupDir(), fmt.Sprintf("config-v%d.json", version)))
+			if err != nil {
+				t.Fatalf("pre-migration backup: %v", err)
+			}
+			if !bytes.Equal(backup, original) {
+				t.Error("pre-migration backup differs from the original file")
+			}
+			written, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(written), fmt.Sprintf(`"version": %d`, currentConfigVersion)) || strings.Contains(string(written), "breaker") {
+				t.Errorf("config file was not written back in the current format:\n%s", written)
+			}
+
+			if len(notices) == 0 || !strings.Contains(notices[0], "升级") {
+				t.Errorf("migration was not reported, notices: %v", notices)
+			}
+
+			// 升级前的备份出现在备份列表中，不参与轮换，可以恢复
+			backups, err := ms.ListBackups()
+			if err != nil {
+				t.Fatalf("ListBackups: %v", err)
+			}
+			name := fmt.Sprintf("config-v%d.json", version)
+			var listed *BackupInfo
+			for i := range backups {
+				if backups[i].Name == name {
+					listed = &backups[i]
+				}
+			}
+			if listed == nil || !listed.PreMigration || listed.FromVersion != version || listed.Time.IsZero() {
+				t.Fatalf("ListBackups() = %+v, want %s as a pre-migration backup", backups, name)
+			}
+			for i := 0; i < defaultBackupCount+1; i++ {
+				if _, err := ms.AddRule(Rule{AppName: fmt.Sprintf("App%d", i), Input: InputList{"com.apple.keylayout.ABC"}, Enabled: true}); err != nil {
+					t.Fatalf("AddRule: %v", err)
+				}
+			}
+			if err := ms.RestoreBackup(name); err != nil {
+				t.Fatalf("RestoreBackup(%s): %v", name, err)
+			}
+			if rule := ms.MatchWindow(&WindowInfo{AppName: "App0"}); rule != nil {
+				t.Errorf("rule added after the migration matches after restoring: %+v", rule)
+			}
+			if rule := ms.MatchWindow(&WindowInfo{AppName: "Terminal"}); rule == nil || rule.Input.String() != "com.apple.keylayout.ABC" {
+				t.Errorf("MatchWindow(Terminal) after restoring = %v", rule)
+			}
+			if config := ms.GetConfig(); config.Version != currentConfigVersion {
+				t.Errorf("restored config version = %d, want %d", config.Version, currentConfigVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// 规则的匹配模式
+const (
+	MatchExact    = "exact"    // 完全相同（忽略大小写）
+	MatchPrefix   = "prefix"   // 前缀匹配
+	MatchGlob     = "glob"     // 通配符匹配，* 匹配任意字符，? 匹配单个字符
+	MatchRegex    = "regex"    // 正则表达式
+	MatchContains = "contains" // 包含匹配
+)
+
+// pattern 编译后的匹配模式
+type pattern struct {
+	mode  string
+	raw   string
+	lower string
+	re    *regexp.Regexp
+}
+
+// compilePattern 按指定模式编译匹配串，glob 和 regex 在此处编译为正则表达式
+func compilePattern(mode, raw string) (*pattern, error) {
+	raw = strings.TrimSpace(raw)
+	p := &pattern{
+		mode:  mode,
+		raw:   raw,
+		lower: strings.ToLower(raw),
+	}
+
+	switch mode {
+	case MatchExact, MatchPrefix, MatchContains:
+	case MatchGlob:
+		re, err := regexp.Compile(globToRegex(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %v", raw, err)
+		}
+		p.re = re
+	case MatchRegex:
+		re, err := regexp.Compile("(?i)" + raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %v", raw, err)
+		}
+		p.re = re
+	default:
+		return nil, fmt.Errorf("unknown match mode %q (expected exact, prefix, glob, regex or contains)", mode)
+	}
+
+	return p, nil
+}
+
+// globToRegex 将通配符模式转换为忽略大小写、完整匹配的正则表达式
+func globToRegex(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("(?i)^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+// match 判断值是否匹配模式（忽略大小写）
+func (p *pattern) match(value string) bool {
+	value = strings.TrimSpace(value)
+	switch p.mode {
+	case MatchExact:
+		return strings.EqualFold(value, p.raw)
+	case MatchPrefix:
+		return strings.HasPrefix(strings.ToLower(value), p.lower)
+	case MatchContains:
+		return strings.Contains(strings.ToLower(value), p.lower)
+	default:
+		return p.re.MatchString(value)
+	}
+}
+
+// wordTokens 将名称按非字母数字字符拆分为小写单词
+func wordTokens(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsTokens 判断 needle 的单词序列是否连续出现在 haystack 中
+func containsTokens(haystack, needle []string) bool {
+	if len(needle) == 0 || len(needle) > len(haystack) {
+		return false
+	}
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		matched := true
+		for j := range needle {
+			if haystack[i+j] != needle[j] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// fuzzyAppMatch 模糊匹配应用名称
+// 规则中的名称需要作为完整单词出现在应用名称中，例如 "Chrome" 匹配 "Google Chrome"；
+// 包名形式（如 com.google.Chrome）按最后一段匹配
+func fuzzyAppMatch(appName, ruleAppName string) bool {
+	appTokens := wordTokens(appName)
+	ruleAppName = strings.TrimSpace(ruleAppName)
+
+	if containsTokens(appTokens, wordTokens(ruleAppName)) {
+		return true
+	}
+
+	if strings.Contains(ruleAppName, ".") && !strings.Contains(ruleAppName, " ") {
+		lastPart := ruleAppName[strings.LastIndex(ruleAppName, ".")+1:]
+		return containsTokens(appTokens, wordTokens(lastPart))
+	}
+
+	return false
+}
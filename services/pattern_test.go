@@ -0,0 +1,115 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPatternMatch(t *testing.T) {
+	tests := []struct {
+		mode  string
+		raw   string
+		value string
+		want  bool
+	}{
+		{MatchExact, "Safari", "safari", true},
+		{MatchExact, "Safari", "Safari Technology Preview", false},
+		{MatchPrefix, "Visual", "Visual Studio Code", true},
+		{MatchPrefix, "Studio", "Visual Studio Code", false},
+		{MatchContains, "studio", "Visual Studio Code", true},
+		{MatchGlob, "foo*bar", "foo-baz-bar", true},
+		{MatchGlob, "foo*bar", "xfoobar", false},
+		{MatchGlob, "foo*bar", "foobarbaz", false},
+		{MatchGlob, "IntelliJ IDEA ?E", "IntelliJ IDEA CE", true},
+		{MatchGlob, "a.b", "axb", false},
+		{MatchRegex, `^Google Chrome( Canary)?$`, "google chrome canary", true},
+		{MatchRegex, `^Google Chrome$`, "Google Chrome Canary", false},
+		{MatchRegex, `Chrome|Safari`, "Safari", true},
+	}
+
+	for _, tt := range tests {
+		p, err := compilePattern(tt.mode, tt.raw)
+		if err != nil {
+			t.Fatalf("compilePattern(%s, %q): %v", tt.mode, tt.raw, err)
+		}
+		if got := p.match(tt.value); got != tt.want {
+			t.Errorf("%s %q match %q = %v, want %v", tt.mode, tt.raw, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMatchWindowWindowModes(t *testing.T) {
+	rule := func(id, window, mode string) Rule {
+		r := testRule(id, "Chrome", MatchContains, 1)
+		r.WindowName = window
+		r.WindowMatch = mode
+		return r
+	}
+
+	tests := []struct {
+		name   string
+		rule   Rule
+		window string
+		want   bool
+	}{
+		{"contains by default", rule("r", "GitHub", ""), "GitHub - Pull requests", true},
+		{"star selects glob", rule("r", "*.go - VS*", ""), "main.go - VS Code", true},
+		{"exact", rule("r", "Inbox", MatchExact), "Inbox (3)", false},
+		{"prefix", rule("r", "Inbox", MatchPrefix), "Inbox (3)", true},
+		{"regex", rule("r", `\(\d+\)$`, MatchRegex), "Inbox (3)", true},
+		{"glob is not a substring match", rule("r", "foo*bar", MatchGlob), "xfoobar", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := newTestMatcher(t, []Rule{tt.rule})
+			got := ms.MatchWindow(&WindowInfo{AppName: "Google Chrome", WindowName: tt.window}) != nil
+			if got != tt.want {
+				t.Errorf("window %q matched = %v, want %v", tt.window, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigRejectsInvalidPatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:    "invalid regex",
+			rule:    map[string]interface{}{"app": "Chrome(", "appMatch": MatchRegex},
+			wantErr: "rules[1].app: invalid regex",
+		},
+		{
+			name:    "unknown app mode",
+			rule:    map[string]interface{}{"app": "Chrome", "appMatch": "exatc"},
+			wantErr: `rules[1].app: unknown match mode "exatc"`,
+		},
+		{
+			name:    "invalid window regex",
+			rule:    map[string]interface{}{"app": "Chrome", "window": "[", "windowMatch": MatchRegex},
+			wantErr: "rules[1].window: invalid regex",
+		},
+		{
+			name:    "unknown window mode without a window",
+			rule:    map[string]interface{}{"app": "Chrome", "windowMatch": "sometimes"},
+			wantErr: `rules[1].windowMatch: unknown match mode "sometimes"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule["input"] = "com.apple.keylayout.ABC"
+			tt.rule["enabled"] = true
+			_, err := loadTestConfig(t, map[string]interface{}{
+				"version": currentConfigVersion,
+				"rules":   []interface{}{map[string]interface{}{"app": "Terminal", "input": "com.apple.keylayout.ABC", "enabled": true}, tt.rule},
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadConfig error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/tailscale/hujson"
+)
+
+// LayerPolicy 管理策略层，位于所有层之上
+const LayerPolicy = "policy"
+
+// policyFileNames 查找管理策略文件时依次尝试的文件名，位于系统配置目录下
+var policyFileNames = []string{"policy.json", "policy.jsonc", "policy.yaml", "policy.yml", "policy.toml"}
+
+// policyRuleSource 管理策略中的规则使用的来源名称
+// rules.d 中的文件名总是带有扩展名，不会与之重复
+const policyRuleSource = "policy"
+
+// ErrLocked 试图修改被管理策略锁定的配置项或规则
+var ErrLocked = errors.New("locked by policy")
+
+// Policy 管理策略文件，由管理员放在系统配置目录下，用于统一管理的电脑
+type Policy struct {
+	Settings map[string]interface{} `json:"settings,omitempty"` // 强制的配置项，结构与配置文件相同，覆盖其他各层并自动锁定
+	Locked   []string               `json:"locked,omitempty"`   // 额外锁定的配置项位置，例如 general.ignoreApps、rules，只使用默认值和系统配置中的值
+	Rules    []Rule                 `json:"rules,omitempty"`    // 强制的规则，优先于其他规则参与匹配，不能修改或删除
+}
+
+// policyLocked 读取管理策略文件，文件不存在时返回 nil，调用方需持有锁
+func (ms *MatcherService) policyLocked() (*Policy, error) {
+	if ms.systemConfigDir == "" {
+		return nil, nil
+	}
+
+	for _, name := range policyFileNames {
+		path := filepath.Join(ms.systemConfigDir, name)
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read policy %s: %v", path, err)
+		}
+		return parsePolicy(path, data)
+	}
+	return nil, nil
+}
+
+// parsePolicy 解析管理策略文件，为没有ID的规则生成固定的ID
+func parsePolicy(path string, data []byte) (*Policy, error) {
+	converted, err := decodeConfigData(data, configFormat(path))
+	if err != nil {
+		return nil, fmt.Errorf("policy %s: %v", path, err)
+	}
+	var policy Policy
+	if err := json.Unmarshal(converted, &policy); err != nil {
+		return nil, fmt.Errorf("policy %s: %s", path, describeJSONError(converted, err))
+	}
+
+	for i, p := range policy.Locked {
+		if p == "" || strings.HasPrefix(p, ".") || strings.HasSuffix(p, ".") || strings.Contains(p, "..") {
+			return nil, fmt.Errorf("policy %s: locked[%d]: invalid setting path %q", path, i, p)
+		}
+	}
+	delete(policy.Settings, "version")
+	delete(policy.Settings, "lastModified")
+
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		rule.Source = policyRuleSource
+		rule.Locked = true
+		rule.Breaker = nil
+		if rule.ID == "" {
+			rule.ID = ruleSourceID(policyRuleSource, i)
+		}
+	}
+	return &policy, nil
+}
+
+// lockedPaths 返回被锁定的配置项位置：强制的配置项和额外锁定的配置项，按名称排序
+func (p *Policy) lockedPaths() []string {
+	if p == nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var paths []string
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	var walk func(layer map[string]interface{}, prefix string)
+	walk = func(layer map[string]interface{}, prefix string) {
+		for key, value := range layer {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			if sub, ok := value.(map[string]interface{}); ok && len(sub) > 0 {
+				walk(sub, path)
+				continue
+			}
+			add(path)
+		}
+	}
+	walk(p.Settings, "")
+	for _, path := range p.Locked {
+		add(path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// withPolicyRules 将管理策略中的规则放在规则文件之前，替换之前加入的策略规则
+func withPolicyRules(includes []RuleSource, policy *Policy) []RuleSource {
+	var result []RuleSource
+	if policy != nil && len(policy.Rules) > 0 {
+		result = append(result, RuleSource{Name: policyRuleSource, Rules: policy.Rules})
+	}
+	for _, source := range includes {
+		if source.Name != policyRuleSource {
+			result = append(result, source)
+		}
+	}
+	return result
+}
+
+// layerValue 按配置项位置取出层中的值
+func layerValue(layer map[string]interface{}, path string) (interface{}, bool) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := layer[part].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		layer = next
+	}
+	value, exists := layer[parts[len(parts)-1]]
+	return value, exists
+}
+
+// deleteLayerValue 按配置项位置删除层中的值
+func deleteLayerValue(layer map[string]interface{}, path string) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := layer[part].(map[string]interface{})
+		if !ok {
+			return
+		}
+		layer = next
+	}
+	delete(layer, parts[len(parts)-1])
+}
+
+// lockLayer 将锁定的配置项恢复为下层 lower（默认值和系统配置）中的值，忽略用户配置、环境变量和命令行参数的修改
+func lockLayer(merged, lower map[string]interface{}, lowerSources map[string]string, paths []string, sources map[string]string) {
+	for _, path := range paths {
+		clearSources(sources, path)
+		value, exists := layerValue(lower, path)
+		if !exists {
+			deleteLayerValue(merged, path)
+			continue
+		}
+		setLayerValue(merged, path, value)
+		for p, source := range lowerSources {
+			if p == path || strings.HasPrefix(p, path+".") {
+				sources[p] = source
+			}
+		}
+	}
+}
+
+// restoreLockedValue 将对象中锁定位置的值恢复为用户配置文件 user 中原来的值，原文件中没有时删除，
+// 锁定的配置项不由用户配置文件决定，保存时保持原文件中的内容
+func restoreLockedValue(obj *hujson.Object, path string, user map[string]interface{}) {
+	name, rest, nested := strings.Cut(path, ".")
+	userValue, inUser := user[name]
+	for i := range obj.Members {
+		m := &obj.Members[i]
+		if memberName(*m) != name {
+			continue
+		}
+		if sub, ok := m.Value.Value.(*hujson.Object); ok && nested {
+			subUser, _ := userValue.(map[string]interface{})
+			restoreLockedValue(sub, rest, subUser)
+			return
+		}
+		if !inUser {
+			obj.Members = append(obj.Members[:i], obj.Members[i+1:]...)
+			return
+		}
+		if value, err := hujsonValue(userValue); err == nil {
+			m.Value.Value = value.Value
+		}
+		return
+	}
+
+	if inUser {
+		if value, err := hujsonValue(userValue); err == nil {
+			obj.Members = append(obj.Members, hujson.ObjectMember{Name: hujson.Value{Value: hujson.String(name)}, Value: value})
+		}
+	}
+}
+
+// hujsonValue 将值转换为 hujson 语法树中的值
+func hujsonValue(value interface{}) (hujson.Value, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return hujson.Value{}, err
+	}
+	return hujson.Parse(data)
+}
+
+// isLocked 判断配置项是否被锁定：该位置或其上级位置被锁定
+func (c *Config) isLocked(path string) bool {
+	for _, locked := range c.Locked {
+		if path == locked || strings.HasPrefix(path, locked+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// markLockedRules 标记被锁定的规则：管理策略中的规则，以及位于锁定位置下的规则
+func (c *Config) markLockedRules() {
+	for _, ref := range c.ruleRefs() {
+		rule := c.rule(ref)
+		switch {
+		case ref.source == policyRuleSource:
+			rule.Locked = true
+		case ref.source != "":
+			rule.Locked = false
+		case ref.profile == "":
+			rule.Locked = c.isLocked("rules")
+		default:
+			rule.Locked = c.isLocked("profiles." + ref.profile + ".rules")
+		}
+	}
+}
+
+// comparableLayer 将配置转换为层，去掉运行时信息，用于比较锁定的配置项是否被修改
+func comparableLayer(c *Config) map[string]interface{} {
+	configCopy := *c
+	configCopy.copyRules()
+	for _, ref := range configCopy.ruleRefs() {
+		rule := configCopy.rule(ref)
+		rule.Breaker = nil
+		rule.Locked = false
+		if ref.source == "" {
+			rule.Source = ""
+		}
+	}
+	configCopy.Locked = nil
+	configCopy.ValueSources = nil
+	configCopy.Version = 0
+	configCopy.LastModified = ""
+	configCopy.Revision = ""
+	return toLayer(configCopy)
+}
+
+// lockedChanges 返回 updated 相对于 current 修改了哪些锁定的配置项
+func lockedChanges(current, updated *Config) []string {
+	if current == nil || len(current.Locked) == 0 {
+		return nil
+	}
+	before := comparableLayer(current)
+	after := comparableLayer(updated)
+
+	var changed []string
+	for _, path := range current.Locked {
+		old, oldExists := layerValue(before, path)
+		value, exists := layerValue(after, path)
+		if oldExists != exists || !reflect.DeepEqual(old, value) {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+// lockedError 返回修改锁定的配置项时的错误
+func lockedError(paths []string) error {
+	return fmt.Errorf("%w: %s", ErrLocked, strings.Join(paths, ", "))
+}
+
+// lockedRuleError 返回修改锁定的规则时的错误
+func lockedRuleError(id string, ref ruleRef, config *Config) error {
+	if ref.source == policyRuleSource {
+		return fmt.Errorf("%w: rule %s is enforced by policy", ErrLocked, id)
+	}
+	path := "rules"
+	if ref.profile != "" {
+		path = "profiles." + ref.profile + ".rules"
+	}
+	for _, locked := range config.Locked {
+		if path == locked || strings.HasPrefix(path, locked+".") {
+			path = locked
+			break
+		}
+	}
+	return fmt.Errorf("%w: rule %s (%s)", ErrLocked, id, path)
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testPolicy = `settings:
+  general:
+    logLevel: warn
+locked:
+  - general.ignoreApps
+  - activeProfile
+rules:
+  - app: Terminal
+    input: com.apple.keylayout.ABC
+    enabled: true
+`
+
+// newPolicyMatcher 加载带有系统配置和管理策略的配置
+func newPolicyMatcher(t *testing.T) (*MatcherService, string) {
+	t.Helper()
+
+	systemDir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(systemDir, "config.json"), []byte(`{"general": {"switchDelay": 250}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(systemDir, "policy.yaml"), []byte(testPolicy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ms, err := loadTestConfig(t, map[string]interface{}{
+		"version":  currentConfigVersion,
+		"general":  map[string]interface{}{"logLevel": "debug", "ignoreApps": []string{"Raycast"}},
+		"profiles": map[string]interface{}{"work": map[string]interface{}{}},
+		"rules":    []Rule{testRule("terminal", "Terminal", MatchExact, 1)},
+	})
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	ms.SetSystemConfigDir(systemDir)
+	if err := ms.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig with policy: %v", err)
+	}
+	return ms, systemDir
+}
+
+func TestPolicyOverridesAndLocks(t *testing.T) {
+	ms, _ := newPolicyMatcher(t)
+	config := ms.GetConfig()
+
+	if config.General.LogLevel != "warn" || config.ValueSource("general.logLevel") != LayerPolicy {
+		t.Errorf("logLevel = %q from %q, want warn from policy", config.General.LogLevel, config.ValueSource("general.logLevel"))
+	}
+	if config.General.SwitchDelay != 250 || config.ValueSource("general.switchDelay") != LayerSystem {
+		t.Errorf("switchDelay = %d from %q, want 250 from system", config.General.SwitchDelay, config.ValueSource("general.switchDelay"))
+	}
+	// 锁定的配置项不使用用户配置中的值
+	if len(config.General.IgnoreApps) != 0 || ms.IsIgnored(&WindowInfo{AppName: "Raycast"}) {
+		t.Errorf("ignoreApps = %v, want the user value to be ignored", config.General.IgnoreApps)
+	}
+	for _, path := range []string{"general.logLevel", "general.ignoreApps", "activeProfile"} {
+		if !config.isLocked(path) {
+			t.Errorf("%s is not locked, locked: %v", path, config.Locked)
+		}
+	}
+
+	// 策略中的规则优先于其他规则
+	rule := ms.MatchWindow(&WindowInfo{AppName: "Terminal"})
+	if rule == nil || rule.Source != policyRuleSource || !rule.Locked {
+		t.Fatalf("MatchWindow(Terminal) = %+v, want the policy rule", rule)
+	}
+}
+
+func TestPolicyRejectsLockedChanges(t *testing.T) {
+	ms, _ := newPolicyMatcher(t)
+	original, err := ioutil.ReadFile(ms.configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := ms.GetConfig()
+	config.General.LogLevel = "error"
+	if err := ms.SaveConfig(config); !errors.Is(err, ErrLocked) {
+		t.Errorf("SaveConfig with a locked logLevel error = %v, want ErrLocked", err)
+	}
+	config = ms.GetConfig()
+	config.General.IgnoreApps = []string{"Alfred"}
+	if err := ms.SaveConfig(config); !errors.Is(err, ErrLocked) {
+		t.Errorf("SaveConfig with locked ignoreApps error = %v, want ErrLocked", err)
+	}
+	if err := ms.SetActiveProfile("work"); !errors.Is(err, ErrLocked) {
+		t.Errorf("SetActiveProfile error = %v, want ErrLocked", err)
+	}
+
+	policyRule := ms.MatchWindow(&WindowInfo{AppName: "Terminal"})
+	update := *policyRule
+	update.Enabled = false
+	if err := ms.UpdateRule(policyRule.ID, update); !errors.Is(err, ErrLocked) {
+		t.Errorf("UpdateRule on a policy rule error = %v, want ErrLocked", err)
+	}
+	if err := ms.DeleteRule(policyRule.ID); !errors.Is(err, ErrLocked) {
+		t.Errorf("DeleteRule on a policy rule error = %v, want ErrLocked", err)
+	}
+
+	if data, _ := ioutil.ReadFile(ms.configPath); !bytes.Equal(data, original) {
+		t.Errorf("config file changed after rejected saves:\n%s", data)
+	}
+	if config := ms.GetConfig(); config.General.LogLevel != "warn" || config.ActiveProfile != "" {
+		t.Errorf("config changed after rejected saves: logLevel %q, activeProfile %q", config.General.LogLevel, config.ActiveProfile)
+	}
+
+	// 未锁定的配置项照常保存，用户配置文件中被覆盖的值保持原样
+	if _, err := ms.AddRule(Rule{AppName: "Safari", Input: InputList{"com.apple.keylayout.ABC"}, Enabled: true}); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	written, err := ioutil.ReadFile(ms.configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(written, []byte(`"debug"`)) || !bytes.Contains(written, []byte(`"Raycast"`)) || bytes.Contains(written, []byte(`"warn"`)) {
+		t.Errorf("user values were rewritten on save:\n%s", written)
+	}
+}
+
+func TestWatcherReloadsPolicyChanges(t *testing.T) {
+	ms, systemDir := newPolicyMatcher(t)
+
+	reloads := make(chan error, 10)
+	ms.SetReloadCallback(func(err error) { reloads <- err })
+	go ms.StartWatching()
+	defer ms.StopWatching()
+
+	time.Sleep(100 * time.Millisecond)
+	policy := bytes.Replace([]byte(testPolicy), []byte("logLevel: warn"), []byte("logLevel: error"), 1)
+	if err := ioutil.WriteFile(filepath.Join(systemDir, "policy.yaml"), policy, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitReload(t, reloads); err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+	if level := ms.GetConfig().General.LogLevel; level != "error" {
+		t.Errorf("logLevel after the policy changed = %q, want error", level)
+	}
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Profile 配置方案，包含只在该方案启用时生效的规则
+type Profile struct {
+	Rules []Rule       `json:"rules,omitempty"` // 方案专属规则，与共享规则一起参与匹配
+	Auto  *ProfileAuto `json:"auto,omitempty"`  // 自动启用条件（可选）
+}
+
+// ProfileAuto 配置方案的自动启用条件，所有声明的条件都满足时启用
+type ProfileAuto struct {
+	Hostnames []string  `json:"hostnames,omitempty"` // 主机名，支持通配符，任意一个匹配即可
+	Schedule  *Schedule `json:"schedule,omitempty"`  // 生效时间
+}
+
+// ProfileStatus 配置方案的状态
+type ProfileStatus struct {
+	Name   string       `json:"name"`           // 方案名称
+	Rules  int          `json:"rules"`          // 方案专属规则数量
+	Auto   *ProfileAuto `json:"auto,omitempty"` // 自动启用条件
+	Active bool         `json:"active"`         // 当前是否启用
+	Manual bool         `json:"manual"`         // 是否为手动选择的方案
+}
+
+// compiledProfile 预编译的配置方案自动启用条件
+type compiledProfile struct {
+	name      string
+	hostnames []*pattern
+	schedule  *compiledSchedule
+}
+
+// matches 判断配置方案的自动启用条件是否满足
+func (cp compiledProfile) matches(hostname string, now time.Time) bool {
+	if len(cp.hostnames) > 0 {
+		matched := false
+		for _, p := range cp.hostnames {
+			if p.match(hostname) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if cp.schedule != nil {
+		if active, _ := cp.schedule.active(now); !active {
+			return false
+		}
+	}
+	return true
+}
+
+// ruleRef 规则在配置中的位置
+type ruleRef struct {
+	profile  string // 所属配置方案，共享规则为空
+	source   string // 所在的 rules.d 文件名，管理策略中的规则为 policy，主配置文件中的规则为空
+	index    int    // 在所属规则列表中的下标
+	location string // 用于错误信息的位置，例如 rules[0]、profiles.work.rules[1]、rules.d/team.json:rules[2]、policy:rules[0]
+}
+
+// profileNames 返回按名称排序的配置方案
+func (c *Config) profileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ruleRefs 按固定顺序列出所有规则：先是共享规则，然后按名称排列各配置方案的规则，
+// 最后是管理策略中的规则和按文件名排列的 rules.d 中的规则
+func (c *Config) ruleRefs() []ruleRef {
+	refs := make([]ruleRef, 0, len(c.Rules))
+	for i := range c.Rules {
+		refs = append(refs, ruleRef{index: i, location: fmt.Sprintf("rules[%d]", i)})
+	}
+	for _, name := range c.profileNames() {
+		for i := range c.Profiles[name].Rules {
+			refs = append(refs, ruleRef{
+				profile:  name,
+				index:    i,
+				location: fmt.Sprintf("profiles.%s.rules[%d]", name, i),
+			})
+		}
+	}
+	for _, source := range c.Includes {
+		for i := range source.Rules {
+			location := fmt.Sprintf("%s/%s:rules[%d]", ruleSourceDirName, source.Name, i)
+			if source.Name == policyRuleSource {
+				location = fmt.Sprintf("%s:rules[%d]", policyRuleSource, i)
+			}
+			refs = append(refs, ruleRef{
+				source:   source.Name,
+				index:    i,
+				location: location,
+			})
+		}
+	}
+	return refs
+}
+
+// rule 返回位置对应的规则
+func (c *Config) rule(ref ruleRef) *Rule {
+	if ref.source != "" {
+		return &c.ruleSource(ref.source).Rules[ref.index]
+	}
+	if ref.profile == "" {
+		return &c.Rules[ref.index]
+	}
+	return &c.Profiles[ref.profile].Rules[ref.index]
+}
+
+// removeRule 删除位置对应的规则，rules.d 中的规则是只读的，由调用方事先检查
+func (c *Config) removeRule(ref ruleRef) {
+	if ref.source != "" {
+		return
+	}
+	if ref.profile == "" {
+		c.Rules = append(c.Rules[:ref.index], c.Rules[ref.index+1:]...)
+		return
+	}
+	profile := c.Profiles[ref.profile]
+	profile.Rules = append(profile.Rules[:ref.index], profile.Rules[ref.index+1:]...)
+	c.Profiles[ref.profile] = profile
+}
+
+// copyRules 复制规则列表、配置方案和规则文件，使修改不影响原配置
+func (c *Config) copyRules() {
+	c.Rules = append([]Rule(nil), c.Rules...)
+	if c.Includes != nil {
+		includes := make([]RuleSource, len(c.Includes))
+		for i, source := range c.Includes {
+			source.Rules = append([]Rule(nil), source.Rules...)
+			includes[i] = source
+		}
+		c.Includes = includes
+	}
+	if c.Profiles == nil {
+		return
+	}
+	profiles := make(map[string]Profile, len(c.Profiles))
+	for name, profile := range c.Profiles {
+		profile.Rules = append([]Rule(nil), profile.Rules...)
+		profiles[name] = profile
+	}
+	c.Profiles = profiles
+}
+
+// compileProfiles 校验配置方案并编译自动启用条件
+func compileProfiles(config *Config) ([]compiledProfile, []error) {
+	var compiled []compiledProfile
+	var errs []error
+	for _, name := range config.profileNames() {
+		cp, err := compileProfile(name, config.Profiles[name])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if cp != nil {
+			compiled = append(compiled, *cp)
+		}
+	}
+
+	if config.ActiveProfile != "" {
+		if _, exists := config.Profiles[config.ActiveProfile]; !exists {
+			errs = append(errs, configErrorf("activeProfile", "unknown profile %q", config.ActiveProfile))
+		}
+	}
+
+	return compiled, errs
+}
+
+// compileProfile 编译单个配置方案的自动启用条件，没有自动启用条件时返回 nil
+func compileProfile(name string, profile Profile) (*compiledProfile, error) {
+	path := "profiles." + name
+	if strings.TrimSpace(name) == "" || strings.Contains(name, ".") {
+		return nil, configErrorf(path, "profile name must not be empty or contain '.'")
+	}
+
+	auto := profile.Auto
+	if auto == nil {
+		return nil, nil
+	}
+	if len(auto.Hostnames) == 0 && auto.Schedule == nil {
+		return nil, configErrorf(path+".auto", "at least one of hostnames or schedule is required")
+	}
+
+	cp := &compiledProfile{name: name}
+	for i, hostname := range auto.Hostnames {
+		p, err := compilePattern(defaultPatternMode(hostname, MatchExact), hostname)
+		if err != nil {
+			return nil, configErrorf(fmt.Sprintf("%s.auto.hostnames[%d]", path, i), "%v", err)
+		}
+		cp.hostnames = append(cp.hostnames, p)
+	}
+	if auto.Schedule != nil {
+		schedule, err := compileSchedule(auto.Schedule)
+		if err != nil {
+			return nil, wrapConfigError(path+".auto.schedule", err)
+		}
+		cp.schedule = schedule
+	}
+	return cp, nil
+}
+
+// localHostname 返回本机主机名，获取失败时返回空字符串
+func localHostname() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return hostname
+}
+
+// activeProfile 返回快照中启用的配置方案，manual 表示是否为手动选择
+// 没有手动选择时，按名称顺序启用第一个满足自动启用条件的方案
+func (s *configSnapshot) activeProfile(hostname string, now time.Time) (name string, manual bool) {
+	if s.config.ActiveProfile != "" {
+		return s.config.ActiveProfile, true
+	}
+	for _, cp := range s.compiled.profiles {
+		if cp.matches(hostname, now) {
+			return cp.name, false
+		}
+	}
+	return "", false
+}
+
+// ActiveProfile 返回当前启用的配置方案，没有启用任何方案时返回空字符串
+func (ms *MatcherService) ActiveProfile() (name string, manual bool) {
+	snapshot := ms.current()
+	if snapshot == nil {
+		return "", false
+	}
+	return snapshot.activeProfile(ms.hostname, ms.currentTime())
+}
+
+// GetProfiles 获取所有配置方案及其状态
+func (ms *MatcherService) GetProfiles() []ProfileStatus {
+	snapshot := ms.current()
+	if snapshot == nil {
+		return nil
+	}
+
+	active, manual := snapshot.activeProfile(ms.hostname, ms.currentTime())
+	config := snapshot.config.Clone()
+	var profiles []ProfileStatus
+	for _, name := range config.profileNames() {
+		profile := config.Profiles[name]
+		profiles = append(profiles, ProfileStatus{
+			Name:   name,
+			Rules:  len(profile.Rules),
+			Auto:   profile.Auto,
+			Active: name == active,
+			Manual: manual && name == active,
+		})
+	}
+	return profiles
+}
+
+// SetActiveProfile 手动选择配置方案并写入配置文件，传入空字符串表示恢复自动选择
+func (ms *MatcherService) SetActiveProfile(name string) error {
+	return ms.Update(func(config *Config) error {
+		if name != "" {
+			if _, exists := config.Profiles[name]; !exists {
+				return fmt.Errorf("profile not found: %s", name)
+			}
+		}
+
+		config.ActiveProfile = name
+		return nil
+	})
+}
+
+// AddRuleToProfile 向指定配置方案添加新规则，返回分配了ID的规则
+func (ms *MatcherService) AddRuleToProfile(name string, rule Rule) (*Rule, error) {
+	if rule.Source != "" {
+		return nil, fmt.Errorf("%w: cannot add rules to %s/%s", ErrReadOnlyRule, ruleSourceDirName, rule.Source)
+	}
+
+	ms.ruleMutex.Lock()
+	defer ms.ruleMutex.Unlock()
+
+	config, err := ms.updateLocked(func(config *Config) error {
+		profile, exists := config.Profiles[name]
+		if !exists {
+			return fmt.Errorf("profile not found: %s", name)
+		}
+
+		// 设置默认值
+		if rule.Priority == 0 {
+			rule.Priority = len(profile.Rules) + 1
+		}
+
+		// 新规则总是使用新生成的随机ID，删除后再添加同样的规则也不会沿用旧规则的ID
+		rule.ID = newRuleID()
+		profile.Rules = append(profile.Rules, rule.Clone())
+		config.Profiles[name] = profile
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rules := config.Profiles[name].Rules
+	added := rules[len(rules)-1].Clone()
+	return &added, nil
+}
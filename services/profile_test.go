@@ -0,0 +1,180 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// newProfileMatcher 加载包含共享规则和两个配置方案的配置
+func newProfileMatcher(t *testing.T) *MatcherService {
+	t.Helper()
+
+	ms, err := loadTestConfig(t, map[string]interface{}{
+		"version": currentConfigVersion,
+		"rules":   []Rule{testRule("shared", "Terminal", MatchExact, 1)},
+		"profiles": map[string]interface{}{
+			"home": map[string]interface{}{
+				"rules": []Rule{testRule("home-chrome", "Chrome", MatchContains, 1)},
+				"auto":  map[string]interface{}{"schedule": map[string]interface{}{"weekdays": []string{"sat-sun"}}},
+			},
+			"work": map[string]interface{}{
+				"rules": []Rule{testRule("work-chrome", "Chrome", MatchContains, 1), testRule("work-terminal", "Terminal", MatchExact, 1)},
+				"auto":  map[string]interface{}{"hostnames": []string{"work-*"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	return ms
+}
+
+func TestProfileAutoSelection(t *testing.T) {
+	tests := []struct {
+		name     string
+		hostname string
+		now      time.Time
+		want     string
+		chrome   string
+	}{
+		{"hostname matches", "work-laptop", time.Date(2024, 3, 4, 10, 0, 0, 0, time.Local), "work", "work-chrome"},
+		{"schedule matches", "home-mac", time.Date(2024, 3, 9, 10, 0, 0, 0, time.Local), "home", "home-chrome"},
+		{"first profile by name wins", "work-laptop", time.Date(2024, 3, 9, 10, 0, 0, 0, time.Local), "home", "home-chrome"},
+		{"no profile", "home-mac", time.Date(2024, 3, 4, 10, 0, 0, 0, time.Local), "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := newProfileMatcher(t)
+			ms.hostname = tt.hostname
+			ms.SetClock(func() time.Time { return tt.now })
+
+			if name, manual := ms.ActiveProfile(); name != tt.want || manual {
+				t.Errorf("ActiveProfile() = %q, %v, want %q, false", name, manual, tt.want)
+			}
+			got := ""
+			if rule := ms.MatchWindow(&WindowInfo{AppName: "Google Chrome"}); rule != nil {
+				got = rule.ID
+			}
+			if got != tt.chrome {
+				t.Errorf("MatchWindow(Google Chrome) = %q, want %q", got, tt.chrome)
+			}
+		})
+	}
+}
+
+func TestProfileRulesWinTies(t *testing.T) {
+	ms := newProfileMatcher(t)
+	ms.hostname = "work-laptop"
+	ms.SetClock(func() time.Time { return time.Date(2024, 3, 4, 10, 0, 0, 0, time.Local) })
+
+	// 得分和优先级相同时方案中的规则优先于共享规则
+	if rule := ms.MatchWindow(&WindowInfo{AppName: "Terminal"}); rule == nil || rule.ID != "work-terminal" {
+		t.Errorf("MatchWindow(Terminal) = %v, want the profile rule", rule)
+	}
+
+	// 方案未启用时使用共享规则
+	ms.hostname = "home-mac"
+	if rule := ms.MatchWindow(&WindowInfo{AppName: "Terminal"}); rule == nil || rule.ID != "shared" {
+		t.Errorf("MatchWindow(Terminal) without a profile = %v, want the shared rule", rule)
+	}
+}
+
+func TestSetActiveProfile(t *testing.T) {
+	ms := newProfileMatcher(t)
+	ms.hostname = "home-mac"
+	ms.SetClock(func() time.Time { return time.Date(2024, 3, 4, 10, 0, 0, 0, time.Local) })
+
+	if err := ms.SetActiveProfile("work"); err != nil {
+		t.Fatalf("SetActiveProfile: %v", err)
+	}
+	if name, manual := ms.ActiveProfile(); name != "work" || !manual {
+		t.Errorf("ActiveProfile() = %q, %v, want work, true", name, manual)
+	}
+	for _, status := range ms.GetProfiles() {
+		if status.Active != (status.Name == "work") || status.Manual != (status.Name == "work") {
+			t.Errorf("profile status %+v", status)
+		}
+	}
+
+	if err := ms.SetActiveProfile("gaming"); err == nil {
+		t.Error("SetActiveProfile accepted an unknown profile")
+	}
+
+	// 手动选择写入配置文件，重新加载后保持
+	if err := ms.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if name, _ := ms.ActiveProfile(); name != "work" {
+		t.Errorf("ActiveProfile() after reload = %q, want work", name)
+	}
+
+	if err := ms.SetActiveProfile(""); err != nil {
+		t.Fatalf("SetActiveProfile: %v", err)
+	}
+	if name, manual := ms.ActiveProfile(); name != "" || manual {
+		t.Errorf("ActiveProfile() after clearing = %q, %v, want automatic selection", name, manual)
+	}
+}
+
+func TestAddRuleToProfile(t *testing.T) {
+	ms := newProfileMatcher(t)
+	ms.hostname = "work-laptop"
+	ms.SetClock(func() time.Time { return time.Date(2024, 3, 4, 10, 0, 0, 0, time.Local) })
+
+	added, err := ms.AddRuleToProfile("work", Rule{AppName: "Slack", Input: InputList{"com.apple.keylayout.ABC"}, Enabled: true})
+	if err != nil {
+		t.Fatalf("AddRuleToProfile: %v", err)
+	}
+	if rule := ms.MatchWindow(&WindowInfo{AppName: "Slack"}); rule == nil || rule.ID != added.ID {
+		t.Errorf("MatchWindow(Slack) = %v, want the added rule %s", rule, added.ID)
+	}
+
+	if err := ms.SetActiveProfile("home"); err != nil {
+		t.Fatal(err)
+	}
+	if rule := ms.MatchWindow(&WindowInfo{AppName: "Slack"}); rule != nil {
+		t.Errorf("rule from an inactive profile matched: %+v", rule)
+	}
+
+	if _, err := ms.AddRuleToProfile("gaming", Rule{AppName: "Steam", Input: InputList{"com.apple.keylayout.ABC"}, Enabled: true}); err == nil {
+		t.Error("AddRuleToProfile accepted an unknown profile")
+	}
+}
+
+func TestLoadConfigRejectsInvalidProfiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:    "unknown active profile",
+			config:  map[string]interface{}{"activeProfile": "gaming", "profiles": map[string]interface{}{"work": map[string]interface{}{}}},
+			wantErr: `activeProfile: unknown profile "gaming"`,
+		},
+		{
+			name:    "empty auto",
+			config:  map[string]interface{}{"profiles": map[string]interface{}{"work": map[string]interface{}{"auto": map[string]interface{}{}}}},
+			wantErr: "profiles.work.auto: at least one of hostnames or schedule is required",
+		},
+		{
+			name: "invalid schedule",
+			config: map[string]interface{}{"profiles": map[string]interface{}{"home": map[string]interface{}{
+				"auto": map[string]interface{}{"schedule": map[string]interface{}{"times": []string{"9am-5pm"}}},
+			}}},
+			wantErr: "profiles.home.auto.schedule.times[0]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config["version"] = currentConfigVersion
+			_, err := loadTestConfig(t, tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadConfig error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
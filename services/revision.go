@@ -0,0 +1,76 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// ErrConflict 保存配置时配置文件已被其他程序（例如编辑器）修改
+var ErrConflict = errors.New("config file was modified")
+
+// ConflictError 保存配置时的冲突：调用方基于的版本与配置文件当前的内容不一致
+// 可以用 errors.Is(err, ErrConflict) 判断
+type ConflictError struct {
+	Path     string `json:"path"`     // 配置文件
+	Base     string `json:"base"`     // 调用方基于的版本
+	Revision string `json:"revision"` // 配置文件当前的版本，文件不存在时为空
+	Diff     string `json:"diff"`     // 本次要写入的内容与配置文件当前内容的差异（unified diff）
+}
+
+// Error 返回冲突说明和差异
+func (e *ConflictError) Error() string {
+	message := fmt.Sprintf("%v: %s changed since revision %s", ErrConflict, e.Path, shortRevision(e.Base))
+	if e.Revision != "" {
+		message += fmt.Sprintf(" (now %s)", shortRevision(e.Revision))
+	}
+	if e.Diff != "" {
+		message += "\n" + e.Diff
+	}
+	return message
+}
+
+// Unwrap 使 errors.Is(err, ErrConflict) 成立
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+// configRevision 根据配置文件内容计算版本，内容相同时版本相同
+func configRevision(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// fileRevision 返回配置文件内容对应的版本，文件不存在（err 不为空）时为空字符串
+func fileRevision(data []byte, err error) string {
+	if err != nil {
+		return ""
+	}
+	return configRevision(data)
+}
+
+// shortRevision 返回便于显示的短版本号
+func shortRevision(revision string) string {
+	if revision == "" {
+		return "(none)"
+	}
+	if len(revision) > 12 {
+		return revision[:12]
+	}
+	return revision
+}
+
+// conflictError 生成冲突错误，差异从本次要写入的内容 proposed 到配置文件当前的内容 current
+func (ms *MatcherService) conflictError(base, revision string, proposed, current []byte) error {
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(proposed)),
+		B:        difflib.SplitLines(string(current)),
+		FromFile: "unsaved",
+		ToFile:   ms.configPath,
+		Context:  3,
+	})
+	return &ConflictError{Path: ms.configPath, Base: base, Revision: revision, Diff: diff}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestSaveConfigRevision(t *testing.T) {
+	ms := newTestMatcher(t, []Rule{testRule("terminal", "Terminal", MatchExact, 1)})
+
+	config := ms.GetConfig()
+	if config.Revision == "" {
+		t.Fatal("GetConfig returned no revision")
+	}
+	config.General.SwitchDelay = 300
+	if err := ms.SaveConfig(config); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	saved := ms.GetConfig()
+	if saved.Revision == config.Revision {
+		t.Error("revision did not change after saving")
+	}
+
+	// 基于旧版本再次保存时冲突
+	config.General.SwitchDelay = 400
+	err := ms.SaveConfig(config)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("SaveConfig with a stale revision error = %v, want ErrConflict", err)
+	}
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Base != config.Revision || conflict.Revision != saved.Revision {
+		t.Errorf("ConflictError = %+v, want base %s and revision %s", conflict, config.Revision, saved.Revision)
+	}
+	if got := ms.GetConfig().General.SwitchDelay; got != 300 {
+		t.Errorf("switchDelay after the conflict = %d, want 300", got)
+	}
+}
+
+func TestSaveConfigExternalEdit(t *testing.T) {
+	ms := newTestMatcher(t, []Rule{testRule("terminal", "Terminal", MatchExact, 1)})
+	config := ms.GetConfig()
+
+	// 在编辑器中修改配置文件后，基于之前版本的保存不会覆盖修改
+	edited, err := ioutil.ReadFile(ms.configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited = bytes.Replace(edited, []byte(`"Terminal"`), []byte(`"iTerm2"`), 1)
+	if err := ioutil.WriteFile(ms.configPath, edited, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config.General.SwitchDelay = 300
+	err = ms.SaveConfig(config)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("SaveConfig error = %v, want *ConflictError", err)
+	}
+	if !strings.Contains(conflict.Diff, `-      "app": "Terminal",`) || !strings.Contains(conflict.Diff, `+      "app": "iTerm2",`) {
+		t.Errorf("conflict diff does not show the external edit:\n%s", conflict.Diff)
+	}
+	if data, _ := ioutil.ReadFile(ms.configPath); !bytes.Equal(data, edited) {
+		t.Errorf("edited config file was overwritten:\n%s", data)
+	}
+
+	// 修改规则的接口同样检测冲突，重新加载后可以保存
+	if _, err := ms.AddRule(Rule{AppName: "Safari", Input: InputList{"com.apple.keylayout.ABC"}, Enabled: true}); !errors.Is(err, ErrConflict) {
+		t.Errorf("AddRule after an external edit error = %v, want ErrConflict", err)
+	}
+	if err := ms.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if _, err := ms.AddRule(Rule{AppName: "Safari", Input: InputList{"com.apple.keylayout.ABC"}, Enabled: true}); err != nil {
+		t.Errorf("AddRule after reloading: %v", err)
+	}
+}
+
+func TestSaveConfigWithoutRevision(t *testing.T) {
+	ms := newTestMatcher(t, []Rule{testRule("terminal", "Terminal", MatchExact, 1)})
+	config := ms.GetConfig()
+	config.Revision = ""
+	if err := ms.SaveConfig(config); !errors.Is(err, ErrConflict) {
+		t.Errorf("SaveConfig without a revision error = %v, want ErrConflict", err)
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ruleSourceDirName 存放附加规则文件的目录，位于配置文件所在目录下
+const ruleSourceDirName = "rules.d"
+
+// ErrReadOnlyRule 试图通过接口修改 rules.d 中的只读规则
+var ErrReadOnlyRule = errors.New("rule source is read-only")
+
+// RuleSource rules.d 目录中的一个规则文件
+// 这些规则与主配置文件中的共享规则一起参与匹配，但只能通过编辑文件修改
+type RuleSource struct {
+	Name  string `json:"name"`  // 文件名
+	Rules []Rule `json:"rules"` // 文件中的规则
+}
+
+// ruleSourceFile 规则文件的格式，与主配置文件相同可以使用 JSON（JSONC）、YAML 或 TOML
+type ruleSourceFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// ruleSourceDir 返回 rules.d 目录
+func (ms *MatcherService) ruleSourceDir() string {
+	return filepath.Join(filepath.Dir(ms.configPath), ruleSourceDirName)
+}
+
+// isRuleSourceFile 判断文件名是否为规则文件：支持的扩展名，且不是隐藏文件（编辑器的临时文件等）
+func isRuleSourceFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".jsonc", ".yaml", ".yml", ".toml":
+		return true
+	}
+	return false
+}
+
+// loadRuleSources 按文件名顺序读取 rules.d 目录中的规则文件，目录不存在时返回 nil
+func loadRuleSources(dir string) ([]RuleSource, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules directory: %v", err)
+	}
+
+	var sources []RuleSource
+	for _, entry := range entries {
+		if entry.IsDir() || !isRuleSourceFile(entry.Name()) {
+			continue
+		}
+		source, err := loadRuleSource(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, *source)
+	}
+	return sources, nil
+}
+
+// loadRuleSource 读取单个规则文件，为没有ID的规则生成固定的ID
+func loadRuleSource(path string) (*RuleSource, error) {
+	name := filepath.Base(path)
+	location := ruleSourceDirName + "/" + name
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, configErrorf(location, "failed to read rules file: %v", err)
+	}
+	converted, err := decodeConfigData(data, configFormat(path))
+	if err != nil {
+		return nil, configErrorf(location, "%v", err)
+	}
+	var file ruleSourceFile
+	if err := json.Unmarshal(converted, &file); err != nil {
+		return nil, configErrorf(location, "failed to parse rules file: %s", describeJSONError(converted, err))
+	}
+
+	for i := range file.Rules {
+		rule := &file.Rules[i]
+		rule.Source = name
+		rule.Breaker = nil
+		// 只读文件中的规则无法写回ID，按文件名和位置生成，重新加载后保持不变
+		if rule.ID == "" {
+			rule.ID = ruleSourceID(name, i)
+		}
+	}
+	return &RuleSource{Name: name, Rules: file.Rules}, nil
+}
+
+// ruleSourceID 为规则文件中没有ID的规则生成ID
+func ruleSourceID(name string, index int) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s:%d", name, index)))
+	return hex.EncodeToString(sum[:6])
+}
+
+// ruleSource 返回指定名称的规则文件
+func (c *Config) ruleSource(name string) *RuleSource {
+	for i := range c.Includes {
+		if c.Includes[i].Name == name {
+			return &c.Includes[i]
+		}
+	}
+	return nil
+}
+
+// ruleSourceErrors 检查规则文件中的规则ID：规则文件无法写回新ID，重复时只能报错
+// 主配置文件中与之重复的规则会在保存时分配新ID，不在这里检查
+func ruleSourceErrors(config *Config) []error {
+	var errs []error
+	seen := make(map[string]string)
+	for _, ref := range config.ruleRefs() {
+		if ref.source == "" {
+			continue
+		}
+		id := config.rule(ref).ID
+		if other, exists := seen[id]; exists {
+			errs = append(errs, configErrorf(ref.location+".id", "duplicate rule id %q (also used by %s)", id, other))
+			continue
+		}
+		seen[id] = ref.location
+	}
+	return errs
+}
+
+// readOnlyRuleError 返回修改只读规则时的错误
+func readOnlyRuleError(id string, ref ruleRef) error {
+	return fmt.Errorf("%w: rule %s is defined in %s/%s", ErrReadOnlyRule, id, ruleSourceDirName, ref.source)
+}
+
+// GetRuleSources 获取 rules.d 目录中加载的规则文件，不包括管理策略中的规则
+func (ms *MatcherService) GetRuleSources() []RuleSource {
+	config := ms.GetConfig()
+	if config == nil {
+		return nil
+	}
+	var sources []RuleSource
+	for _, source := range config.Includes {
+		if source.Name != policyRuleSource {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeRuleSource 在配置文件旁的 rules.d 目录中写入规则文件
+func writeRuleSource(t *testing.T, ms *MatcherService, name, data string) {
+	t.Helper()
+
+	if err := os.MkdirAll(ms.ruleSourceDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(ms.ruleSourceDir(), name), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRuleSources(t *testing.T) {
+	ms := newTestMatcher(t, []Rule{testRule("terminal", "Terminal", MatchExact, 1)})
+	writeRuleSource(t, ms, "team.yaml", `rules:
+  - id: team-slack
+    app: Slack
+    input: com.apple.keylayout.ABC
+    enabled: true
+  - app: Figma
+    input: com.apple.keylayout.ABC
+    enabled: true
+`)
+	writeRuleSource(t, ms, "personal.json", `{"rules": [{"app": "Notes", "input": "com.apple.keylayout.ABC", "enabled": true}]}`)
+	writeRuleSource(t, ms, ".team.yaml.swp", "not a rules file")
+	if err := ms.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	sources := ms.GetRuleSources()
+	if len(sources) != 2 || sources[0].Name != "personal.json" || sources[1].Name != "team.yaml" {
+		t.Fatalf("GetRuleSources() = %+v, want personal.json and team.yaml", sources)
+	}
+
+	rule := ms.MatchWindow(&WindowInfo{AppName: "Figma"})
+	if rule == nil || rule.Source != "team.yaml" || rule.ID != ruleSourceID("team.yaml", 1) {
+		t.Fatalf("MatchWindow(Figma) = %+v, want the rule from team.yaml with a generated ID", rule)
+	}
+	if rule := ms.MatchWindow(&WindowInfo{AppName: "Slack"}); rule == nil || rule.ID != "team-slack" {
+		t.Errorf("MatchWindow(Slack) = %+v", rule)
+	}
+
+	// 规则文件中的规则不能通过接口修改
+	update := *rule
+	update.Enabled = false
+	if err := ms.UpdateRule(rule.ID, update); !errors.Is(err, ErrReadOnlyRule) {
+		t.Errorf("UpdateRule error = %v, want ErrReadOnlyRule", err)
+	}
+	if err := ms.DeleteRule(rule.ID); !errors.Is(err, ErrReadOnlyRule) {
+		t.Errorf("DeleteRule error = %v, want ErrReadOnlyRule", err)
+	}
+	if _, err := ms.AddRule(update); !errors.Is(err, ErrReadOnlyRule) {
+		t.Errorf("AddRule with a source error = %v, want ErrReadOnlyRule", err)
+	}
+	if rule := ms.MatchWindow(&WindowInfo{AppName: "Figma"}); rule == nil || !rule.Enabled {
+		t.Errorf("read-only rule changed: %+v", rule)
+	}
+
+	// 保存主配置文件时不写入规则文件中的规则
+	if _, err := ms.AddRule(Rule{AppName: "Safari", Input: InputList{"com.apple.keylayout.ABC"}, Enabled: true}); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	written, err := ioutil.ReadFile(ms.configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(written), "Figma") || strings.Contains(string(written), "source") {
+		t.Errorf("rules from rules.d were written to the config file:\n%s", written)
+	}
+}
+
+func TestRuleSourceErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name: "duplicate IDs across files",
+			files: map[string]string{
+				"a.json": `{"rules": [{"id": "shared", "app": "Slack", "input": "com.apple.keylayout.ABC", "enabled": true}]}`,
+				"b.json": `{"rules": [{"id": "shared", "app": "Figma", "input": "com.apple.keylayout.ABC", "enabled": true}]}`,
+			},
+			wantErr: `rules.d/b.json:rules[0].id: duplicate rule id "shared"`,
+		},
+		{
+			name:    "invalid file",
+			files:   map[string]string{"team.toml": "rules = ["},
+			wantErr: "rules.d/team.toml",
+		},
+		{
+			name:    "invalid rule",
+			files:   map[string]string{"team.json": `{"rules": [{"app": "Slack", "appMatch": "exatc", "input": "com.apple.keylayout.ABC", "enabled": true}]}`},
+			wantErr: `rules.d/team.json:rules[0].app: unknown match mode "exatc"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := newTestMatcher(t, []Rule{testRule("terminal", "Terminal", MatchExact, 1)})
+			for name, data := range tt.files {
+				writeRuleSource(t, ms, name, data)
+			}
+			err := ms.LoadConfig()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadConfig error = %v, want %q", err, tt.wantErr)
+			}
+			// 加载失败时继续使用之前的配置
+			if rule := ms.MatchWindow(&WindowInfo{AppName: "Terminal"}); rule == nil {
+				t.Error("previous config was dropped after a failed load")
+			}
+		})
+	}
+}
+
+func TestConfigRuleIDCollidingWithRuleSource(t *testing.T) {
+	ms := newTestMatcher(t, []Rule{testRule("terminal", "Terminal", MatchExact, 1)})
+	writeRuleSource(t, ms, "team.json", `{"rules": [{"id": "terminal", "app": "iTerm2", "input": "com.apple.keylayout.ABC", "enabled": true}]}`)
+	if err := ms.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	// 主配置文件中与规则文件重复的ID被重新分配
+	rule := ms.MatchWindow(&WindowInfo{AppName: "Terminal"})
+	if rule == nil || rule.ID == "terminal" || rule.Source != "" {
+		t.Errorf("MatchWindow(Terminal) = %+v, want the config rule with a new ID", rule)
+	}
+	if rule := ms.MatchWindow(&WindowInfo{AppName: "iTerm2"}); rule == nil || rule.ID != "terminal" {
+		t.Errorf("MatchWindow(iTerm2) = %+v, want the rules.d rule to keep its ID", rule)
+	}
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schedule 规则的生效时间，所有条件同时满足时规则才生效
+type Schedule struct {
+	Times    []string `json:"times,omitempty"`    // 时间段，例如 "09:00-18:00"，结束时间小于开始时间表示跨越午夜
+	Weekdays []string `json:"weekdays,omitempty"` // 星期，例如 "mon"、"sat"，也可以写成范围 "mon-fri"
+	From     string   `json:"from,omitempty"`     // 开始日期 YYYY-MM-DD（含）
+	To       string   `json:"to,omitempty"`       // 结束日期 YYYY-MM-DD（含）
+	TimeZone string   `json:"timeZone,omitempty"` // IANA 时区，例如 "Asia/Shanghai"，留空使用本地时区
+}
+
+// timeWindow 一天内的时间段，以分钟表示
+type timeWindow struct {
+	start int
+	end   int
+}
+
+// contains 判断分钟数是否在时间段内
+func (tw timeWindow) contains(minute int) bool {
+	if tw.start <= tw.end {
+		return minute >= tw.start && minute < tw.end
+	}
+	// 跨越午夜
+	return minute >= tw.start || minute < tw.end
+}
+
+// compiledSchedule 预解析的生效时间
+type compiledSchedule struct {
+	windows  []timeWindow
+	weekdays map[time.Weekday]bool // 为 nil 表示不限制星期
+	from     string                // YYYY-MM-DD，为空表示不限制
+	to       string
+	location *time.Location
+}
+
+// weekdayNames 星期名称
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// compileSchedule 解析生效时间配置
+func compileSchedule(schedule *Schedule) (*compiledSchedule, error) {
+	cs := &compiledSchedule{location: time.Local}
+
+	if schedule.TimeZone != "" {
+		location, err := time.LoadLocation(schedule.TimeZone)
+		if err != nil {
+			return nil, configErrorf("timeZone", "unknown time zone %q", schedule.TimeZone)
+		}
+		cs.location = location
+	}
+
+	for i, value := range schedule.Times {
+		window, err := parseTimeWindow(value)
+		if err != nil {
+			return nil, configErrorf(fmt.Sprintf("times[%d]", i), "%v", err)
+		}
+		cs.windows = append(cs.windows, window)
+	}
+
+	for i, value := range schedule.Weekdays {
+		if cs.weekdays == nil {
+			cs.weekdays = make(map[time.Weekday]bool)
+		}
+		if err := parseWeekdays(value, cs.weekdays); err != nil {
+			return nil, configErrorf(fmt.Sprintf("weekdays[%d]", i), "%v", err)
+		}
+	}
+
+	// 按固定顺序检查，两个日期都有错时总是先报告 from
+	dates := []struct {
+		field string
+		value string
+	}{
+		{"from", schedule.From},
+		{"to", schedule.To},
+	}
+	for _, date := range dates {
+		if date.value == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date.value); err != nil {
+			return nil, configErrorf(date.field, "invalid date %q (expected YYYY-MM-DD)", date.value)
+		}
+	}
+	if schedule.From != "" && schedule.To != "" && schedule.From > schedule.To {
+		return nil, configErrorf("from", "%s is after to %s", schedule.From, schedule.To)
+	}
+	cs.from = schedule.From
+	cs.to = schedule.To
+
+	return cs, nil
+}
+
+// parseClock 解析 HH:MM 格式的时间，返回从午夜起的分钟数
+// 除了 00:00 到 23:59，还可以用 24:00 表示一天结束；"9:00am" 这样带多余内容的写法会被拒绝
+func parseClock(value string) (int, error) {
+	if value == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (expected HH:MM)", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parseTimeWindow 解析 "HH:MM-HH:MM" 格式的时间段
+func parseTimeWindow(value string) (timeWindow, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 2 {
+		return timeWindow{}, fmt.Errorf("invalid time range %q (expected HH:MM-HH:MM)", value)
+	}
+
+	start, err := parseClock(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return timeWindow{}, err
+	}
+	end, err := parseClock(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return timeWindow{}, err
+	}
+	if start == end {
+		return timeWindow{}, fmt.Errorf("empty time range %q", value)
+	}
+	return timeWindow{start: start, end: end}, nil
+}
+
+// parseWeekdays 解析星期或星期范围（如 "mon-fri"、"sat-sun"），结果写入 weekdays
+func parseWeekdays(value string, weekdays map[time.Weekday]bool) error {
+	value = strings.ToLower(strings.TrimSpace(value))
+	parts := strings.Split(value, "-")
+	if len(parts) > 2 {
+		return fmt.Errorf("invalid weekday %q", value)
+	}
+
+	start, exists := weekdayNames[parts[0]]
+	if !exists {
+		return fmt.Errorf("invalid weekday %q (expected sun, mon, tue, wed, thu, fri or sat)", parts[0])
+	}
+	end := start
+	if len(parts) == 2 {
+		if end, exists = weekdayNames[parts[1]]; !exists {
+			return fmt.Errorf("invalid weekday %q (expected sun, mon, tue, wed, thu, fri or sat)", parts[1])
+		}
+	}
+
+	for day := start; ; day = (day + 1) % 7 {
+		weekdays[day] = true
+		if day == end {
+			break
+		}
+	}
+	return nil
+}
+
+// active 判断某一时刻是否在生效时间内，不在时返回原因
+func (cs *compiledSchedule) active(now time.Time) (bool, string) {
+	local := now.In(cs.location)
+
+	date := local.Format("2006-01-02")
+	if cs.from != "" && date < cs.from {
+		return false, fmt.Sprintf("日期 %s 早于 %s", date, cs.from)
+	}
+	if cs.to != "" && date > cs.to {
+		return false, fmt.Sprintf("日期 %s 晚于 %s", date, cs.to)
+	}
+
+	if cs.weekdays != nil && !cs.weekdays[local.Weekday()] {
+		return false, fmt.Sprintf("%s 不在生效的星期内", local.Weekday())
+	}
+
+	if len(cs.windows) > 0 {
+		minute := local.Hour()*60 + local.Minute()
+		inWindow := false
+		for _, window := range cs.windows {
+			if window.contains(minute) {
+				inWindow = true
+				break
+			}
+		}
+		if !inWindow {
+			return false, fmt.Sprintf("%s 不在生效的时间段内", local.Format("15:04"))
+		}
+	}
+
+	return true, ""
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"00:00", 0, false},
+		{"09:30", 570, false},
+		{"9:30", 570, false},
+		{"23:59", 1439, false},
+		{"24:00", 1440, false},
+		{"9:00am", 0, true},
+		{"09:00:00", 0, true},
+		{"24:30", 0, true},
+		{"12:60", 0, true},
+		{"-1:00", 0, true},
+		{"noon", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseClock(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseClock(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseClock(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCompileScheduleErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		wantErr  string
+	}{
+		{"time with suffix", Schedule{Times: []string{"09:00-18:00", "9:00am-12:00"}}, "times[1]: invalid time"},
+		{"empty range", Schedule{Times: []string{"09:00-09:00"}}, "times[0]: empty time range"},
+		{"unknown weekday", Schedule{Weekdays: []string{"mon-fri", "sunday"}}, "weekdays[1]: invalid weekday"},
+		{"unknown time zone", Schedule{TimeZone: "Mars/Olympus"}, "timeZone: unknown time zone"},
+		{"invalid to", Schedule{From: "2024-01-01", To: "2024-13-01"}, "to: invalid date"},
+		{"both dates invalid, from first", Schedule{From: "2024-02-30", To: "tomorrow"}, "from: invalid date"},
+		{"from after to", Schedule{From: "2024-02-01", To: "2024-01-01"}, "from: 2024-02-01 is after to"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 多次编译得到相同的错误
+			for i := 0; i < 20; i++ {
+				_, err := compileSchedule(&tt.schedule)
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("compileSchedule error = %v, want %q", err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestScheduleActive(t *testing.T) {
+	schedule, err := compileSchedule(&Schedule{
+		Times:    []string{"22:00-02:00", "09:00-12:00"},
+		Weekdays: []string{"mon-fri"},
+		From:     "2024-01-01",
+		To:       "2024-12-31",
+		TimeZone: "Asia/Shanghai",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{"morning window", time.Date(2024, 3, 4, 9, 0, 0, 0, shanghai), true},
+		{"window end is exclusive", time.Date(2024, 3, 4, 12, 0, 0, 0, shanghai), false},
+		{"window across midnight", time.Date(2024, 3, 5, 1, 30, 0, 0, shanghai), true},
+		{"weekend", time.Date(2024, 3, 9, 10, 0, 0, 0, shanghai), false},
+		{"before from", time.Date(2023, 12, 29, 10, 0, 0, 0, shanghai), false},
+		{"after to", time.Date(2025, 1, 2, 10, 0, 0, 0, shanghai), false},
+		{"converted to the schedule time zone", time.Date(2024, 3, 4, 1, 30, 0, 0, time.UTC), true},
+	}
+
+	for _, tt := range tests {
+		got, reason := schedule.active(tt.now)
+		if got != tt.want {
+			t.Errorf("%s: active(%v) = %v (%s), want %v", tt.name, tt.now, got, reason, tt.want)
+		}
+		if !got && reason == "" {
+			t.Errorf("%s: inactive without a reason", tt.name)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+// configSnapshot 配置快照：配置、对应的文件内容和预编译的规则表
+// 快照发布后不再修改，读取方通过 current 取得后不需要加锁；修改配置时生成新的快照整体替换
+type configSnapshot struct {
+	generation uint64          // 快照序号，每次替换加一
+	config     *Config         // 当前配置
+	data       []byte          // 配置对应的文件内容，用于识别应用自身的写入
+	compiled   *compiledConfig // 预编译的规则、忽略列表和配置方案
+}
+
+// current 返回当前配置快照，配置未加载时返回 nil
+func (ms *MatcherService) current() *configSnapshot {
+	return ms.snapshot.Load()
+}
+
+// publishLocked 使用已校验的配置生成新的快照并替换当前快照，调用方需持有写锁
+// 之后不能再修改 config
+func (ms *MatcherService) publishLocked(config *Config, compiled *compiledConfig, data []byte) {
+	var generation uint64
+	if previous := ms.current(); previous != nil {
+		generation = previous.generation
+	}
+	ms.snapshot.Store(&configSnapshot{
+		generation: generation + 1,
+		config:     config,
+		data:       data,
+		compiled:   compiled,
+	})
+	ms.breaker.Configure(config.General.BreakerThreshold, time.Duration(config.General.BreakerBackoff)*time.Millisecond)
+}
+
+// Generation 返回当前配置快照的序号，配置每次加载或修改后加一，配置未加载时为 0
+func (ms *MatcherService) Generation() uint64 {
+	if snapshot := ms.current(); snapshot != nil {
+		return snapshot.generation
+	}
+	return 0
+}
+
+// currentTime 返回时钟的当前时间
+func (ms *MatcherService) currentTime() time.Time {
+	return ms.now.Load().(func() time.Time)()
+}
+
+// Update 在当前配置的副本上执行 fn 并保存，保存成功后替换当前配置快照
+// fn 返回错误或保存失败时放弃修改，当前配置不受影响；多个修改依次执行，fn 中不能再调用 MatcherService 的修改方法
+func (ms *MatcherService) Update(fn func(*Config) error) error {
+	ms.ruleMutex.Lock()
+	defer ms.ruleMutex.Unlock()
+
+	_, err := ms.updateLocked(fn)
+	return err
+}
+
+// updateLocked 在当前配置的副本上执行 fn 并保存，返回修改后的副本（规则已分配ID），调用方需持有写锁
+func (ms *MatcherService) updateLocked(fn func(*Config) error) (*Config, error) {
+	snapshot := ms.current()
+	if snapshot == nil {
+		return nil, fmt.Errorf("config not loaded")
+	}
+
+	config := snapshot.config.Clone()
+	if err := fn(config); err != nil {
+		return nil, err
+	}
+	if err := ms.saveConfigLocked(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Clone 返回配置的深拷贝，修改副本不会影响原配置
+func (c *Config) Clone() *Config {
+	if c == nil {
+		return nil
+	}
+
+	clone := *c
+	clone.Inputs = cloneInputMap(c.Inputs)
+	clone.Rules = cloneRules(c.Rules)
+	if c.Profiles != nil {
+		clone.Profiles = make(map[string]Profile, len(c.Profiles))
+		for name, profile := range c.Profiles {
+			profile.Rules = cloneRules(profile.Rules)
+			if profile.Auto != nil {
+				auto := *profile.Auto
+				auto.Hostnames = cloneStrings(auto.Hostnames)
+				auto.Schedule = auto.Schedule.clone()
+				profile.Auto = &auto
+			}
+			clone.Profiles[name] = profile
+		}
+	}
+	clone.General.IgnoreApps = cloneStrings(c.General.IgnoreApps)
+	clone.General.Fallback.Input = InputList(cloneStrings(c.General.Fallback.Input))
+	if c.Includes != nil {
+		clone.Includes = make([]RuleSource, len(c.Includes))
+		for i, source := range c.Includes {
+			source.Rules = cloneRules(source.Rules)
+			clone.Includes[i] = source
+		}
+	}
+	if c.ValueSources != nil {
+		clone.ValueSources = make(map[string]string, len(c.ValueSources))
+		for path, source := range c.ValueSources {
+			clone.ValueSources[path] = source
+		}
+	}
+	clone.Locked = cloneStrings(c.Locked)
+	return &clone
+}
+
+// Clone 返回规则的深拷贝
+func (r Rule) Clone() Rule {
+	r.Input = InputList(cloneStrings(r.Input))
+	r.Schedule = r.Schedule.clone()
+	if r.Exclude != nil {
+		exclude := *r.Exclude
+		exclude.App = cloneStrings(exclude.App)
+		exclude.Window = cloneStrings(exclude.Window)
+		exclude.AppPath = cloneStrings(exclude.AppPath)
+		r.Exclude = &exclude
+	}
+	if r.Breaker != nil {
+		breaker := *r.Breaker
+		r.Breaker = &breaker
+	}
+	return r
+}
+
+// clone 返回生效时间的深拷贝
+func (s *Schedule) clone() *Schedule {
+	if s == nil {
+		return nil
+	}
+	clone := *s
+	clone.Times = cloneStrings(s.Times)
+	clone.Weekdays = cloneStrings(s.Weekdays)
+	return &clone
+}
+
+// cloneRules 深拷贝规则列表，保持 nil 与空列表的区别
+func cloneRules(rules []Rule) []Rule {
+	if rules == nil {
+		return nil
+	}
+	clone := make([]Rule, len(rules))
+	for i, rule := range rules {
+		clone[i] = rule.Clone()
+	}
+	return clone
+}
+
+// cloneInputMap 深拷贝输入法别名
+func cloneInputMap(inputs map[string]InputList) map[string]InputList {
+	if inputs == nil {
+		return nil
+	}
+	clone := make(map[string]InputList, len(inputs))
+	for alias, list := range inputs {
+		clone[alias] = InputList(cloneStrings(list))
+	}
+	return clone
+}
+
+// cloneStrings 复制字符串列表，保持 nil 与空列表的区别
+func cloneStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string{}, values...)
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// 并发修改规则的同时匹配窗口和读取配置，配合 go test -race 检查快照的并发安全
+func TestSnapshotConcurrentEditsAndMatches(t *testing.T) {
+	ms := newTestMatcher(t, []Rule{
+		testRule("terminal", "Terminal", MatchExact, 1),
+		testRule("chrome", "Chrome", MatchContains, 1),
+	})
+	startGeneration := ms.Generation()
+
+	const writers = 4
+	const iterations = 15
+
+	var writeWG, readWG sync.WaitGroup
+	stop := make(chan struct{})
+	errs := make(chan error, writers*iterations+16)
+
+	for w := 0; w < writers; w++ {
+		writeWG.Add(1)
+		go func(w int) {
+			defer writeWG.Done()
+			for i := 0; i < iterations; i++ {
+				app := fmt.Sprintf("App-%d-%d", w, i)
+				added, err := ms.AddRule(Rule{AppName: app, Input: InputList{"com.test.added"}, Enabled: true, Priority: 2})
+				if err != nil {
+					errs <- fmt.Errorf("AddRule: %v", err)
+					return
+				}
+				updated := *added
+				updated.Priority = 3
+				updated.Input = InputList{"com.test.updated"}
+				if err := ms.UpdateRule(added.ID, updated); err != nil {
+					errs <- fmt.Errorf("UpdateRule: %v", err)
+					return
+				}
+				if err := ms.DeleteRule(added.ID); err != nil {
+					errs <- fmt.Errorf("DeleteRule: %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	readers := []func() error{
+		func() error {
+			rule := ms.MatchWindow(&WindowInfo{AppName: "Terminal"})
+			if rule == nil || rule.ID != "terminal" {
+				return fmt.Errorf("MatchWindow(Terminal) = %v", rule)
+			}
+			// 修改返回的规则不影响当前配置
+			rule.Input = InputList{"com.test.modified"}
+			return nil
+		},
+		func() error {
+			config := ms.GetConfig()
+			seen := make(map[string]bool)
+			for _, rule := range config.Rules {
+				if rule.ID == "" || seen[rule.ID] {
+					return fmt.Errorf("GetConfig returned a rule with a missing or duplicate ID: %+v", rule)
+				}
+				seen[rule.ID] = true
+			}
+			if !seen["terminal"] || !seen["chrome"] {
+				return fmt.Errorf("GetConfig lost the original rules: %v", seen)
+			}
+			config.Rules = append(config.Rules[:0], testRule("modified", "Modified", MatchExact, 1))
+			return nil
+		},
+		func() error {
+			explanation := ms.Explain(&WindowInfo{AppName: "Google Chrome"})
+			if explanation == nil || explanation.Decision == nil || explanation.Decision.ID != "chrome" {
+				return fmt.Errorf("Explain(Google Chrome) = %+v", explanation)
+			}
+			return nil
+		},
+		func() error {
+			before := ms.Generation()
+			ms.GetConfig()
+			if after := ms.Generation(); after < before {
+				return fmt.Errorf("generation went backwards: %d -> %d", before, after)
+			}
+			return nil
+		},
+	}
+	for _, read := range readers {
+		readWG.Add(1)
+		go func(read func() error) {
+			defer readWG.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if err := read(); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(read)
+	}
+
+	writeWG.Wait()
+	close(stop)
+	readWG.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// 所有修改都已生效：新增的规则都被删除，每次修改生成一个新快照
+	config := ms.GetConfig()
+	if len(config.Rules) != 2 {
+		t.Errorf("got %d rules after all edits, want 2: %+v", len(config.Rules), config.Rules)
+	}
+	if got, want := ms.Generation()-startGeneration, uint64(writers*iterations*3); got != want {
+		t.Errorf("generation advanced by %d, want %d", got, want)
+	}
+}
+
+// Update 中返回错误时放弃修改，当前配置和快照保持不变
+func TestSnapshotUpdateRollback(t *testing.T) {
+	ms := newTestMatcher(t, []Rule{testRule("terminal", "Terminal", MatchExact, 1)})
+	generation := ms.Generation()
+
+	errAbort := errors.New("abort")
+	err := ms.Update(func(config *Config) error {
+		config.Rules[0].Input = InputList{"com.test.changed"}
+		config.Rules = append(config.Rules, testRule("extra", "Extra", MatchExact, 1))
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Update returned %v, want %v", err, errAbort)
+	}
+
+	if ms.Generation() != generation {
+		t.Errorf("generation changed from %d to %d after a failed update", generation, ms.Generation())
+	}
+	config := ms.GetConfig()
+	if len(config.Rules) != 1 || config.Rules[0].Input.String() != "com.test.terminal" {
+		t.Errorf("config changed after a failed update: %+v", config.Rules)
+	}
+	if rule := ms.MatchWindow(&WindowInfo{AppName: "Extra"}); rule != nil {
+		t.Errorf("rule from a failed update is matched: %+v", rule)
+	}
+}
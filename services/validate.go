@@ -0,0 +1,501 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tailscale/hujson"
+)
+
+// ConfigError 带位置的配置错误
+type ConfigError struct {
+	Path    string // 出错的配置项，例如 rules[2].window
+	Message string
+}
+
+// Error 返回 "位置: 说明" 形式的错误信息
+func (e *ConfigError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// configErrorf 创建带位置的配置错误
+func configErrorf(path, format string, args ...interface{}) error {
+	return &ConfigError{Path: path, Message: fmt.Sprintf(format, args...)}
+}
+
+// wrapConfigError 在错误位置前加上外层配置项，例如 schedule 的 times[0] 变为 rules[1].schedule.times[0]
+func wrapConfigError(prefix string, err error) error {
+	var ce *ConfigError
+	if errors.As(err, &ce) {
+		return &ConfigError{Path: prefix + "." + ce.Path, Message: ce.Message}
+	}
+	return &ConfigError{Path: prefix, Message: err.Error()}
+}
+
+// 校验问题的严重程度
+const (
+	SeverityError   = "error"   // 配置无法加载
+	SeverityWarning = "warning" // 配置可以加载，但很可能不是预期的写法
+)
+
+// ValidationIssue 配置校验发现的问题
+type ValidationIssue struct {
+	Severity string `json:"severity"`         // error / warning
+	Path     string `json:"path,omitempty"`   // 配置项位置，例如 rules[2].window
+	Line     int    `json:"line,omitempty"`   // 行号（从 1 开始）
+	Column   int    `json:"column,omitempty"` // 列号（从 1 开始）
+	Message  string `json:"message"`          // 问题说明
+}
+
+// String 返回 "行:列: 级别: 位置: 说明" 形式的文本
+func (vi ValidationIssue) String() string {
+	var b strings.Builder
+	if vi.Line > 0 {
+		fmt.Fprintf(&b, "%d:%d: ", vi.Line, vi.Column)
+	}
+	b.WriteString(vi.Severity)
+	b.WriteString(": ")
+	if vi.Path != "" {
+		b.WriteString(vi.Path)
+		b.WriteString(": ")
+	}
+	b.WriteString(vi.Message)
+	return b.String()
+}
+
+// ValidationResult 配置校验结果
+type ValidationResult struct {
+	Valid  bool              `json:"valid"`  // 没有 error 级别的问题，配置可以加载
+	Issues []ValidationIssue `json:"issues"` // 所有问题，按位置排序
+}
+
+// validator 一次校验的上下文
+type validator struct {
+	data      []byte
+	positions map[string]int // 配置项位置 -> 在文件中的字节偏移
+	issues    []ValidationIssue
+}
+
+// ValidateConfigData 一次性检查配置文件内容中的所有问题
+// availableInputs 为可用的输入法ID，为 nil 时跳过输入法是否可用的检查
+func ValidateConfigData(data []byte, availableInputs []string) *ValidationResult {
+	// 注释和尾随逗号替换为空格，行号和列号不变
+	standard, err := hujson.Standardize(append([]byte(nil), data...))
+	if err != nil {
+		issue := ValidationIssue{Severity: SeverityError}
+		issue.Line, issue.Column, issue.Message = jsoncSyntaxError(err)
+		issue.Message = "invalid JSON: " + issue.Message
+		return &ValidationResult{Issues: []ValidationIssue{issue}}
+	}
+	data = standard
+	v := &validator{data: data, positions: make(map[string]int)}
+
+	root, err := scanJSON(data)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		offset := len(data)
+		if errors.As(err, &syntaxErr) {
+			offset = int(syntaxErr.Offset)
+		}
+		v.addAt(SeverityError, "", offset, fmt.Sprintf("invalid JSON: %v", err))
+		return v.result()
+	}
+
+	// 结构检查：未知字段和类型错误
+	v.checkNode(root, reflect.TypeOf(Config{}), "")
+	if !v.valid() {
+		return v.result()
+	}
+
+	// 版本检查：旧版本会在加载时自动升级，比当前版本新的配置无法加载
+	if _, version, err := migrateConfigData(data); err != nil {
+		var ce *ConfigError
+		if errors.As(err, &ce) {
+			v.add(SeverityError, ce.Path, ce.Message)
+		} else {
+			v.add(SeverityError, "", err.Error())
+		}
+		return v.result()
+	} else if version < currentConfigVersion {
+		v.add(SeverityWarning, "version", fmt.Sprintf("config version %d will be upgraded to version %d when loaded", version, currentConfigVersion))
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		v.add(SeverityError, "", err.Error())
+		return v.result()
+	}
+	applyConfigDefaults(&config)
+
+	// 语义检查：与加载配置时的校验相同，但收集所有问题
+	_, errs := compileConfigAll(&config)
+	for _, err := range errs {
+		var ce *ConfigError
+		if errors.As(err, &ce) {
+			v.add(SeverityError, ce.Path, ce.Message)
+		} else {
+			v.add(SeverityError, "", err.Error())
+		}
+	}
+
+	if availableInputs != nil {
+		v.checkAvailableInputs(&config, availableInputs)
+	}
+
+	return v.result()
+}
+
+// checkAvailableInputs 检查配置中的输入法ID是否在可用输入法中
+// 共享配置可能包含其他机器上才有的输入法，因此只作为警告
+func (v *validator) checkAvailableInputs(config *Config, availableInputs []string) {
+	available := make(map[string]bool)
+	for _, inputID := range availableInputs {
+		available[inputID] = true
+	}
+
+	check := func(path string, inputs InputList) {
+		for _, entry := range inputs {
+			if strings.TrimSpace(entry) == "" || isInputAlias(config, entry) || available[entry] {
+				continue
+			}
+			v.add(SeverityWarning, path, fmt.Sprintf("input %q is not among the available inputs", entry))
+		}
+	}
+
+	aliases := make([]string, 0, len(config.Inputs))
+	for alias := range config.Inputs {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		check("inputs."+alias, config.Inputs[alias])
+	}
+	for _, ref := range config.ruleRefs() {
+		check(ref.location+".input", config.rule(ref).Input)
+	}
+	check("general.fallback.input", config.General.Fallback.Input)
+}
+
+// add 记录问题，位置由配置项推算
+func (v *validator) add(severity, path, message string) {
+	offset := -1
+	for p := path; p != ""; p = parentPath(p) {
+		if o, exists := v.positions[p]; exists {
+			offset = o
+			break
+		}
+	}
+	v.addAt(severity, path, offset, message)
+}
+
+// addAt 记录指定字节偏移处的问题，offset 为负数表示位置未知
+func (v *validator) addAt(severity, path string, offset int, message string) {
+	issue := ValidationIssue{Severity: severity, Path: path, Message: message}
+	if offset >= 0 {
+		issue.Line, issue.Column = lineColumn(v.data, offset)
+	}
+	v.issues = append(v.issues, issue)
+}
+
+// valid 判断目前是否没有 error 级别的问题
+func (v *validator) valid() bool {
+	for _, issue := range v.issues {
+		if issue.Severity == SeverityError {
+			return false
+		}
+	}
+	return true
+}
+
+// result 生成按位置排序的校验结果
+func (v *validator) result() *ValidationResult {
+	sort.SliceStable(v.issues, func(i, j int) bool {
+		if v.issues[i].Line != v.issues[j].Line {
+			return v.issues[i].Line < v.issues[j].Line
+		}
+		return v.issues[i].Column < v.issues[j].Column
+	})
+	return &ValidationResult{Valid: v.valid(), Issues: v.issues}
+}
+
+// parentPath 返回上一级配置项，例如 rules[0].app -> rules[0] -> rules
+func parentPath(path string) string {
+	if strings.HasSuffix(path, "]") {
+		if i := strings.LastIndex(path, "["); i >= 0 {
+			return path[:i]
+		}
+	}
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// lineColumn 将字节偏移转换为行号和列号（列号按字符计算）
+func lineColumn(data []byte, offset int) (int, int) {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	lineStart := bytes.LastIndexByte(before, '\n') + 1
+	return line, utf8.RuneCount(before[lineStart:]) + 1
+}
+
+// describeJSONError 为 JSON 解析错误加上行号和列号
+func describeJSONError(data []byte, err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		line, column := lineColumn(data, int(syntaxErr.Offset))
+		return fmt.Sprintf("line %d, column %d: %v", line, column, err)
+	case errors.As(err, &typeErr):
+		line, column := lineColumn(data, int(typeErr.Offset))
+		return fmt.Sprintf("line %d, column %d: %v", line, column, err)
+	}
+	return err.Error()
+}
+
+// jsonNode 带位置信息的 JSON 值
+type jsonNode struct {
+	offset  int          // 值在文件中的字节偏移
+	kind    byte         // '{' 对象，'[' 数组，'s' 字符串，'n' 数字，'b' 布尔，'z' null
+	number  string       // 数字的原始文本
+	members []jsonMember // 对象成员，保持文件中的顺序
+	elems   []*jsonNode  // 数组元素
+}
+
+// jsonMember 对象成员
+type jsonMember struct {
+	name   string
+	offset int // 键在文件中的字节偏移
+	value  *jsonNode
+}
+
+// scanJSON 解析 JSON 并记录每个值的位置
+// 语法错误由 json.Unmarshal 先行报告，它给出的错误信息和位置比逐个读取记号更准确
+func scanJSON(data []byte) (*jsonNode, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return scanValue(dec, data)
+}
+
+// scanValue 读取一个 JSON 值
+func scanValue(dec *json.Decoder, data []byte) (*jsonNode, error) {
+	offset := nextTokenOffset(data, int(dec.InputOffset()))
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	node := &jsonNode{offset: offset}
+	switch t := token.(type) {
+	case json.Delim:
+		node.kind = byte(t)
+		for dec.More() {
+			if t == '{' {
+				keyOffset := nextTokenOffset(data, int(dec.InputOffset()))
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := scanValue(dec, data)
+				if err != nil {
+					return nil, err
+				}
+				node.members = append(node.members, jsonMember{name: key.(string), offset: keyOffset, value: value})
+			} else {
+				value, err := scanValue(dec, data)
+				if err != nil {
+					return nil, err
+				}
+				node.elems = append(node.elems, value)
+			}
+		}
+		// 读取结束符
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	case string:
+		node.kind = 's'
+	case json.Number:
+		node.kind = 'n'
+		node.number = string(t)
+	case bool:
+		node.kind = 'b'
+	case nil:
+		node.kind = 'z'
+	}
+	return node, nil
+}
+
+// nextTokenOffset 跳过空白和分隔符，返回下一个记号的起始位置
+func nextTokenOffset(data []byte, offset int) int {
+	for offset < len(data) {
+		switch data[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+// kindName 返回 JSON 值类型的名称，用于错误信息
+func (n *jsonNode) kindName() string {
+	switch n.kind {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case 's':
+		return "string"
+	case 'n':
+		return "number"
+	case 'b':
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+// inputListType 输入法列表类型，既可以是字符串也可以是字符串数组
+var inputListType = reflect.TypeOf(InputList{})
+
+// checkNode 按配置结构检查 JSON 值的类型和字段，并记录各配置项的位置
+func (v *validator) checkNode(node *jsonNode, t reflect.Type, path string) {
+	if _, exists := v.positions[path]; !exists {
+		v.positions[path] = node.offset
+	}
+	if node.kind == 'z' {
+		return
+	}
+
+	typeError := func(expected string) {
+		v.addAt(SeverityError, path, node.offset, fmt.Sprintf("expected %s, got %s", expected, node.kindName()))
+	}
+
+	if t == inputListType {
+		switch node.kind {
+		case 's':
+		case '[':
+			for i, elem := range node.elems {
+				v.checkNode(elem, reflect.TypeOf(""), fmt.Sprintf("%s[%d]", path, i))
+			}
+		default:
+			typeError("string or array of strings")
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		v.checkNode(node, t.Elem(), path)
+	case reflect.Struct:
+		if node.kind != '{' {
+			typeError("object")
+			return
+		}
+		v.checkStruct(node, t, path)
+	case reflect.Map:
+		if node.kind != '{' {
+			typeError("object")
+			return
+		}
+		for _, member := range node.members {
+			v.positions[joinPath(path, member.name)] = member.offset
+			v.checkNode(member.value, t.Elem(), joinPath(path, member.name))
+		}
+	case reflect.Slice:
+		if node.kind != '[' {
+			typeError("array")
+			return
+		}
+		for i, elem := range node.elems {
+			v.checkNode(elem, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.String:
+		if node.kind != 's' {
+			typeError("string")
+		}
+	case reflect.Bool:
+		if node.kind != 'b' {
+			typeError("boolean")
+		}
+	case reflect.Int, reflect.Int64:
+		if node.kind != 'n' || strings.ContainsAny(node.number, ".eE") {
+			typeError("integer")
+		}
+	}
+}
+
+// checkStruct 检查对象的字段，未知字段会被加载时忽略，因此作为警告
+func (v *validator) checkStruct(node *jsonNode, t reflect.Type, path string) {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = field.Type
+	}
+
+	for _, member := range node.members {
+		memberPath := joinPath(path, member.name)
+		v.positions[memberPath] = member.offset
+
+		fieldType, exists := fields[member.name]
+		if !exists {
+			message := fmt.Sprintf("unknown field %q", member.name)
+			for name := range fields {
+				if strings.EqualFold(name, member.name) {
+					message += fmt.Sprintf(" (did you mean %q?)", name)
+					break
+				}
+			}
+			v.addAt(SeverityWarning, memberPath, member.offset, message)
+			continue
+		}
+		v.checkNode(member.value, fieldType, memberPath)
+	}
+}
+
+// joinPath 拼接配置项位置
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// ConfigPath 返回配置文件路径
+func (ms *MatcherService) ConfigPath() string {
+	return ms.configPath
+}
+
+// ValidateConfig 校验配置文件，返回所有问题
+func (ms *MatcherService) ValidateConfig(availableInputs []string) (*ValidationResult, error) {
+	data, err := ioutil.ReadFile(ms.configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	return ValidateConfigFile(ms.configPath, data, availableInputs), nil
+}
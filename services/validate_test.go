@@ -0,0 +1,192 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+// findIssue 返回路径和说明都匹配的第一个问题
+func findIssue(result *ValidationResult, path, message string) *ValidationIssue {
+	for i, issue := range result.Issues {
+		if issue.Path == path && strings.Contains(issue.Message, message) {
+			return &result.Issues[i]
+		}
+	}
+	return nil
+}
+
+func TestValidateConfigDataSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		line    int
+		column  int
+		message string
+	}{
+		{
+			name: "missing comma",
+			data: `{
+  "version": 1,
+  "rules": [
+    {"app": "Terminal" "input": "com.apple.keylayout.ABC"}
+  ]
+}`,
+			line:    4,
+			column:  24,
+			message: "invalid JSON",
+		},
+		{
+			name: "unterminated comment",
+			data: `{
+  "version": 1, /* rules
+  "rules": []
+}`,
+			line:    2,
+			column:  17,
+			message: "invalid JSON",
+		},
+		{
+			name:    "unexpected end",
+			data:    "{\n  \"version\": 1,\n  \"rules\": [\n",
+			line:    4,
+			column:  1,
+			message: "invalid JSON",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ValidateConfigData([]byte(tt.data), nil)
+			if result.Valid || len(result.Issues) != 1 {
+				t.Fatalf("ValidateConfigData() = %+v, want a single error", result)
+			}
+			issue := result.Issues[0]
+			if issue.Severity != SeverityError || !strings.Contains(issue.Message, tt.message) {
+				t.Errorf("issue = %+v, want an error containing %q", issue, tt.message)
+			}
+			if issue.Line != tt.line || issue.Column != tt.column {
+				t.Errorf("issue at %d:%d, want %d:%d (%s)", issue.Line, issue.Column, tt.line, tt.column, issue.Message)
+			}
+		})
+	}
+}
+
+func TestValidateConfigDataSemanticErrors(t *testing.T) {
+	data := `{
+  // JSONC 注释不影响行号
+  "version": 1,
+  "rules": [
+    {"app": "Terminal", "input": "com.apple.keylayout.ABC", "enabled": true},
+    {
+      "app": "Chrome",
+      "appMatch": "exatc",
+      "input": "jp",
+      "prority": 2,
+      "enabled": true,
+    },
+  ],
+}`
+	result := ValidateConfigData([]byte(data), nil)
+	if result.Valid {
+		t.Fatalf("ValidateConfigData() = valid, want errors: %+v", result.Issues)
+	}
+
+	// 未知字段只是警告，语义检查照常进行；位置为配置项名称所在的行和列
+	unknown := findIssue(result, "rules[1].prority", `unknown field "prority"`)
+	if unknown == nil || unknown.Severity != SeverityWarning || unknown.Line != 10 || unknown.Column != 7 {
+		t.Errorf("unknown field issue = %+v, want a warning at 10:7", unknown)
+	}
+
+	tests := []struct {
+		path    string
+		message string
+		line    int
+		column  int
+	}{
+		{"rules[1].app", `unknown match mode "exatc"`, 7, 7},
+		{"rules[1].input", `unknown input alias "jp"`, 9, 7},
+	}
+	for _, tt := range tests {
+		issue := findIssue(result, tt.path, tt.message)
+		if issue == nil {
+			t.Errorf("no issue at %s containing %q: %+v", tt.path, tt.message, result.Issues)
+			continue
+		}
+		if issue.Severity != SeverityError || issue.Line != tt.line || issue.Column != tt.column {
+			t.Errorf("issue %s = %d:%d %s, want an error at %d:%d", tt.path, issue.Line, issue.Column, issue.Severity, tt.line, tt.column)
+		}
+	}
+
+	// 问题按位置排序
+	for i := 1; i < len(result.Issues); i++ {
+		a, b := result.Issues[i-1], result.Issues[i]
+		if a.Line > b.Line || (a.Line == b.Line && a.Column > b.Column) {
+			t.Errorf("issues are not sorted by position: %s before %s", a, b)
+		}
+	}
+}
+
+func TestValidateConfigDataTypeErrors(t *testing.T) {
+	data := `{
+  "version": 1,
+  "general": {"checkInterval": "fast"},
+  "rules": [{"app": "Terminal", "input": "com.apple.keylayout.ABC", "enabled": "yes"}]
+}`
+	result := ValidateConfigData([]byte(data), nil)
+	if result.Valid {
+		t.Fatal("ValidateConfigData() = valid, want type errors")
+	}
+	for _, path := range []string{"general.checkInterval", "rules[0].enabled"} {
+		if findIssue(result, path, "") == nil {
+			t.Errorf("no issue at %s: %+v", path, result.Issues)
+		}
+	}
+}
+
+func TestValidateConfigDataAvailableInputs(t *testing.T) {
+	data := `{
+  "version": 1,
+  "inputs": {"cn": ["com.tencent.inputmethod.wetype.pinyin", "com.apple.inputmethod.SCIM.ITABC"]},
+  "general": {"fallback": {"mode": "default", "input": "com.apple.keylayout.US"}},
+  "rules": [
+    {"app": "Terminal", "input": "com.apple.keylayout.ABC", "enabled": true},
+    {"app": "WeChat", "input": ["cn", "com.apple.keylayout.ABC"], "enabled": true}
+  ]
+}`
+	available := []string{"com.apple.keylayout.ABC", "com.tencent.inputmethod.wetype.pinyin"}
+
+	result := ValidateConfigData([]byte(data), available)
+	if !result.Valid {
+		t.Fatalf("unavailable inputs should only be warnings: %+v", result.Issues)
+	}
+
+	want := map[string]string{
+		"inputs.cn":              "com.apple.inputmethod.SCIM.ITABC",
+		"general.fallback.input": "com.apple.keylayout.US",
+	}
+	if len(result.Issues) != len(want) {
+		t.Errorf("got %d issues, want %d: %+v", len(result.Issues), len(want), result.Issues)
+	}
+	for path, input := range want {
+		issue := findIssue(result, path, input)
+		if issue == nil || issue.Severity != SeverityWarning || issue.Line == 0 {
+			t.Errorf("issue for %s at %s = %+v, want a located warning", input, path, issue)
+		}
+	}
+
+	// 无法获取可用输入法时跳过这项检查
+	if result := ValidateConfigData([]byte(data), nil); len(result.Issues) != 0 {
+		t.Errorf("issues without an input list: %+v", result.Issues)
+	}
+}
+
+func TestValidationIssueString(t *testing.T) {
+	issue := ValidationIssue{Severity: SeverityError, Path: "rules[2].input", Line: 12, Column: 18, Message: `unknown input alias "jp"`}
+	if got, want := issue.String(), `12:18: error: rules[2].input: unknown input alias "jp"`; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	issue = ValidationIssue{Severity: SeverityWarning, Message: "no position"}
+	if got, want := issue.String(), "warning: no position"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// configWatchDebounce 配置文件连续写入时，等待文件稳定后再重新加载的时间
+const configWatchDebounce = 300 * time.Millisecond
+
+// configPollInterval 无法使用文件系统通知时轮询配置文件的间隔
+const configPollInterval = 2 * time.Second
+
+// SetReloadCallback 设置配置文件变化后自动重新加载的回调
+// err 不为空表示新配置无效，仍在使用之前的配置
+func (ms *MatcherService) SetReloadCallback(callback func(err error)) {
+	ms.ruleMutex.Lock()
+	defer ms.ruleMutex.Unlock()
+	ms.onReload = callback
+}
+
+// StartWatching 监听配置文件、rules.d 目录以及系统配置目录中的系统配置文件和管理策略文件的变化并自动重新加载，直到调用 StopWatching
+// 优先使用文件系统通知（Linux 上为 inotify），不可用时退回到定时轮询
+func (ms *MatcherService) StartWatching() {
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		// 监听所在目录而不是文件本身：编辑器保存时常常先写临时文件再重命名，
+		// 直接监听文件会在第一次重命名后丢失后续事件
+		if err = watcher.Add(filepath.Dir(ms.configPath)); err == nil {
+			defer watcher.Close()
+			// rules.d 目录可能还不存在，创建后在 watchEvents 中再开始监听
+			watcher.Add(ms.ruleSourceDir())
+			// 系统配置目录通常不可写也不一定存在，无法监听时只能手动重新加载
+			if systemDir := ms.watchedSystemDir(); systemDir != "" {
+				if err := watcher.Add(systemDir); err != nil && !os.IsNotExist(err) {
+					fmt.Printf("无法监听系统配置目录 %s: %v\n", systemDir, err)
+				}
+			}
+			ms.watchEvents(watcher)
+			return
+		}
+		watcher.Close()
+	}
+
+	fmt.Printf("无法监听配置文件变化，改为每 %v 检查一次: %v\n", configPollInterval, err)
+	ms.pollConfig()
+}
+
+// StopWatching 停止监听配置文件
+func (ms *MatcherService) StopWatching() {
+	close(ms.watchStop)
+}
+
+// watchEvents 处理文件系统通知，连续的写入合并为一次重新加载
+func (ms *MatcherService) watchEvents(watcher *fsnotify.Watcher) {
+	configPath := filepath.Clean(ms.configPath)
+	sourceDir := filepath.Clean(ms.ruleSourceDir())
+	systemDir := ms.watchedSystemDir()
+	sourcesChanged := false
+	var debounce <-chan time.Time
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			name := filepath.Clean(event.Name)
+			switch {
+			case name == configPath:
+			case name == sourceDir:
+				if event.Has(fsnotify.Create) {
+					watcher.Add(sourceDir)
+				}
+				sourcesChanged = true
+			case filepath.Dir(name) == sourceDir && isRuleSourceFile(filepath.Base(name)):
+				sourcesChanged = true
+			case systemDir != "" && filepath.Dir(name) == systemDir && isSystemConfigFile(filepath.Base(name)):
+				sourcesChanged = true
+			default:
+				continue
+			}
+			debounce = time.After(configWatchDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			fmt.Printf("监听配置文件出错: %v\n", err)
+		case <-debounce:
+			debounce = nil
+			ms.reloadIfChanged(sourcesChanged)
+			sourcesChanged = false
+		case <-ms.watchStop:
+			return
+		}
+	}
+}
+
+// pollConfig 定时检查配置文件的修改时间和大小，变化后等待文件稳定再重新加载
+func (ms *MatcherService) pollConfig() {
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	var lastModTime time.Time
+	var lastSize int64
+	if info, err := os.Stat(ms.configPath); err == nil {
+		lastModTime, lastSize = info.ModTime(), info.Size()
+	}
+	lastSources := ms.ruleSourcesStamp() + ms.systemConfigStamp()
+	sourcesChanged := false
+	var debounce <-chan time.Time
+
+	for {
+		select {
+		case <-ticker.C:
+			if sources := ms.ruleSourcesStamp() + ms.systemConfigStamp(); sources != lastSources {
+				lastSources = sources
+				sourcesChanged = true
+				debounce = time.After(configWatchDebounce)
+			}
+			info, err := os.Stat(ms.configPath)
+			if err != nil {
+				continue
+			}
+			if info.ModTime().Equal(lastModTime) && info.Size() == lastSize {
+				continue
+			}
+			lastModTime, lastSize = info.ModTime(), info.Size()
+			debounce = time.After(configWatchDebounce)
+		case <-debounce:
+			debounce = nil
+			ms.reloadIfChanged(sourcesChanged)
+			sourcesChanged = false
+		case <-ms.watchStop:
+			return
+		}
+	}
+}
+
+// ruleSourcesStamp 返回 rules.d 中各规则文件的名称、大小和修改时间，轮询时用于发现变化
+func (ms *MatcherService) ruleSourcesStamp() string {
+	entries, err := ioutil.ReadDir(ms.ruleSourceDir())
+	if err != nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, entry := range entries {
+		if !entry.IsDir() && isRuleSourceFile(entry.Name()) {
+			fmt.Fprintf(&b, "%s:%d:%d;", entry.Name(), entry.Size(), entry.ModTime().UnixNano())
+		}
+	}
+	return b.String()
+}
+
+// watchedSystemDir 返回需要监听的系统配置目录，不使用系统配置时为空
+func (ms *MatcherService) watchedSystemDir() string {
+	ms.ruleMutex.RLock()
+	defer ms.ruleMutex.RUnlock()
+	if ms.systemConfigDir == "" {
+		return ""
+	}
+	return filepath.Clean(ms.systemConfigDir)
+}
+
+// systemFileNames 系统配置目录中需要监听的文件：系统配置文件和管理策略文件
+func systemFileNames() []string {
+	return append(append([]string{}, configFileNames...), policyFileNames...)
+}
+
+// isSystemConfigFile 判断系统配置目录中的文件是否为系统配置文件或管理策略文件
+func isSystemConfigFile(name string) bool {
+	for _, candidate := range systemFileNames() {
+		if name == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// systemConfigStamp 返回系统配置文件和管理策略文件的名称、大小和修改时间，轮询时用于发现变化
+func (ms *MatcherService) systemConfigStamp() string {
+	systemDir := ms.watchedSystemDir()
+	if systemDir == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, name := range systemFileNames() {
+		if info, err := os.Stat(filepath.Join(systemDir, name)); err == nil {
+			fmt.Fprintf(&b, "system/%s:%d:%d;", name, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return b.String()
+}
+
+// reloadIfChanged 配置文件内容与当前使用的配置不同，或 rules.d、系统配置和管理策略文件有变化时重新加载
+// 新配置无效时 LoadConfig 不会替换当前配置，错误通过回调报告
+func (ms *MatcherService) reloadIfChanged(sourcesChanged bool) {
+	data, err := ioutil.ReadFile(ms.configPath)
+	if err != nil {
+		// 编辑器保存过程中文件可能暂时不存在，等待下一次事件
+		if !os.IsNotExist(err) {
+			ms.notifyReload(fmt.Errorf("failed to read config file: %v", err))
+		}
+		return
+	}
+
+	// 跳过应用自身保存配置引起的变化
+	snapshot := ms.current()
+	unchanged := snapshot != nil && bytes.Equal(data, snapshot.data)
+	if unchanged && !sourcesChanged {
+		return
+	}
+
+	// 自动重新加载时不为新规则写回ID，避免改写用户正在编辑的文件
+	ms.notifyReload(ms.loadConfig(false))
+}
+
+// notifyReload 调用重新加载回调
+func (ms *MatcherService) notifyReload(err error) {
+	ms.ruleMutex.RLock()
+	callback := ms.onReload
+	ms.ruleMutex.RUnlock()
+
+	if callback != nil {
+		callback(err)
+	}
+}
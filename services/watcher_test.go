@@ -0,0 +1,104 @@
+package services
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestRules 将规则写入配置文件
+func writeTestRules(t *testing.T, path string, rules []Rule) {
+	t.Helper()
+
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"version": currentConfigVersion,
+		"rules":   rules,
+	}, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitReload 等待一次自动重新加载，返回回调收到的错误
+func waitReload(t *testing.T, reloads <-chan error) error {
+	t.Helper()
+
+	select {
+	case err := <-reloads:
+		return err
+	case <-time.After(10 * time.Second):
+		t.Fatal("config was not reloaded")
+		return nil
+	}
+}
+
+func TestWatcherReloadsAfterInvalidStartup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, []byte(`{"version": 1, "rules": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ms := NewMatcherService(path)
+	ms.SetSystemConfigDir("")
+	if err := ms.LoadConfig(); err == nil {
+		t.Fatal("LoadConfig accepted an invalid config")
+	}
+
+	reloads := make(chan error, 10)
+	ms.SetReloadCallback(func(err error) { reloads <- err })
+	go ms.StartWatching()
+	defer ms.StopWatching()
+
+	// 启动时配置无效，改正后自动加载
+	time.Sleep(100 * time.Millisecond)
+	writeTestRules(t, path, []Rule{testRule("terminal", "Terminal", MatchExact, 1)})
+	if err := waitReload(t, reloads); err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+	if rule := ms.MatchWindow(&WindowInfo{AppName: "Terminal"}); rule == nil || rule.ID != "terminal" {
+		t.Errorf("MatchWindow(Terminal) after reload = %v", rule)
+	}
+
+	// 新配置无效时报告错误并继续使用之前的配置
+	if err := ioutil.WriteFile(path, []byte(`{"version": 1, "rules": [{"app": "Safari", "appMatch": "exatc"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitReload(t, reloads); err == nil {
+		t.Error("invalid config reloaded without an error")
+	}
+	if rule := ms.MatchWindow(&WindowInfo{AppName: "Terminal"}); rule == nil || rule.ID != "terminal" {
+		t.Errorf("MatchWindow(Terminal) after an invalid edit = %v, want the previous config", rule)
+	}
+
+	writeTestRules(t, path, []Rule{testRule("safari", "Safari", MatchExact, 1)})
+	if err := waitReload(t, reloads); err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+	if rule := ms.MatchWindow(&WindowInfo{AppName: "Safari"}); rule == nil || rule.ID != "safari" {
+		t.Errorf("MatchWindow(Safari) after reload = %v", rule)
+	}
+}
+
+func TestWatcherIgnoresOwnSaves(t *testing.T) {
+	ms := newTestMatcher(t, []Rule{testRule("terminal", "Terminal", MatchExact, 1)})
+
+	reloads := make(chan error, 10)
+	ms.SetReloadCallback(func(err error) { reloads <- err })
+	go ms.StartWatching()
+	defer ms.StopWatching()
+
+	time.Sleep(100 * time.Millisecond)
+	if _, err := ms.AddRule(Rule{AppName: "Safari", Input: InputList{"com.apple.keylayout.ABC"}, Enabled: true}); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	select {
+	case err := <-reloads:
+		t.Errorf("saving the config triggered a reload (err %v)", err)
+	case <-time.After(configWatchDebounce + time.Second):
+	}
+}
@@ -14,6 +14,8 @@ type WindowInfo struct {
 	AppPath    string `json:"appPath"`
 	WindowName string `json:"windowName"`
 	PID        int    `json:"pid"`
+	DetectedAt     time.Time     `json:"detectedAt"` // 检测到该窗口的时间
+	DetectDuration time.Duration `json:"-"`          // 获取窗口信息的耗时
 }
 
 // WindowService 窗口检测服务
@@ -34,14 +36,25 @@ func NewWindowService() *WindowService {
 
 // GetActiveWindow 获取当前活动窗口信息
 func (ws *WindowService) GetActiveWindow() (*WindowInfo, error) {
+	start := time.Now()
+
+	var window *WindowInfo
+	var err error
 	switch runtime.GOOS {
 	case "darwin":
-		return ws.getActiveWindowMac()
+		window, err = ws.getActiveWindowMac()
 	case "windows":
-		return ws.getActiveWindowWindows()
+		window, err = ws.getActiveWindowWindows()
 	default:
 		return nil, fmt.Errorf("unsupported platform: %s", runtime.GOOS)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	window.DetectedAt = time.Now()
+	window.DetectDuration = window.DetectedAt.Sub(start)
+	return window, nil
 }
 
 // getActiveWindowMac macOS下获取活动窗口